@@ -0,0 +1,61 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kv
+
+import (
+	"context"
+	"io"
+)
+
+// BatchGetter is an optional interface implemented by snapshots and
+// transactions that can read multiple keys in a single operation.
+type BatchGetter interface {
+	// MultiGet reads multiple keys. Returned slices have one entry per input
+	// key, in the same order, holding the value or the error (such as
+	// os.ErrNotExist) from reading that key. Last return value is non-nil when
+	// the whole batch has failed, in which case other return values are nil.
+	MultiGet(ctx context.Context, keys []string) ([]io.Reader, []error, error)
+}
+
+// BatchSetter is an optional interface implemented by transactions that can
+// write multiple keys in a single operation.
+type BatchSetter interface {
+	// MultiSet creates or updates all key-value pairs in the input map. None
+	// of the keys are updated if any key is invalid or any value cannot be
+	// read.
+	MultiSet(ctx context.Context, kvs map[string]io.Reader) error
+}
+
+// GetMany reads multiple keys using the BatchGetter interface when the input
+// implements it and reads the keys one by one otherwise. Return values follow
+// the same conventions as the BatchGetter.MultiGet method.
+func GetMany(ctx context.Context, g Getter, keys []string) ([]io.Reader, []error, error) {
+	if v, ok := g.(BatchGetter); ok {
+		return v.MultiGet(ctx, keys)
+	}
+	values := make([]io.Reader, len(keys))
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		values[i], errs[i] = g.Get(ctx, key)
+	}
+	return values, errs, nil
+}
+
+// SetMany writes multiple key-value pairs using the BatchSetter interface when
+// the input implements it and writes the keys one by one otherwise. Unlike
+// BatchSetter.MultiSet, a failure in the fallback may leave some of the keys
+// updated.
+func SetMany(ctx context.Context, s Setter, kvs map[string]io.Reader) error {
+	if v, ok := s.(BatchSetter); ok {
+		return v.MultiSet(ctx, kvs)
+	}
+	for key, value := range kvs {
+		if err := s.Set(ctx, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kv
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrConditionFailed is returned (possibly wrapped) by the conditional writes
+// when the current value of the key doesn't match the expected value.
+var ErrConditionFailed = errors.New("condition failed")
+
+// ConditionalWriter is an optional interface implemented by databases that can
+// update a single key atomically without a transaction.
+//
+// Values are compared byte-by-byte and keys that do not exist never match an
+// expected value, including an empty value.
+type ConditionalWriter interface {
+	// CompareAndSet updates the key to newValue if its current value is equal
+	// to oldValue. Returns an error wrapping ErrConditionFailed otherwise.
+	CompareAndSet(ctx context.Context, key string, oldValue, newValue io.Reader) error
+
+	// CompareVersionAndSet updates the key to newValue if the commit version of
+	// its current value is equal to the given version, like the versions
+	// reported by the Historian and Watcher interfaces. Zero version matches
+	// only the keys that do not exist. Returns an error wrapping
+	// ErrConditionFailed otherwise.
+	CompareVersionAndSet(ctx context.Context, key string, version int64, newValue io.Reader) error
+
+	// SetIfAbsent creates the key with the given value if it doesn't exist.
+	// Returns an error wrapping ErrConditionFailed otherwise.
+	SetIfAbsent(ctx context.Context, key string, value io.Reader) error
+
+	// DeleteIfEqual deletes the key if its current value is equal to the given
+	// value. Returns an error wrapping ErrConditionFailed otherwise.
+	DeleteIfEqual(ctx context.Context, key string, value io.Reader) error
+}
+
+// CompareAndSet updates a key to newValue if its current value is equal to
+// oldValue. It uses the ConditionalWriter interface when the database
+// implements it and falls back to a transaction with retries otherwise.
+func CompareAndSet(ctx context.Context, db Database, key string, oldValue, newValue io.Reader) error {
+	if cw, ok := db.(ConditionalWriter); ok {
+		return cw.CompareAndSet(ctx, key, oldValue, newValue)
+	}
+	old, err := io.ReadAll(oldValue)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(newValue)
+	if err != nil {
+		return err
+	}
+	return WithReadWriterRetry(ctx, db, nil, func(ctx context.Context, rw ReadWriter) error {
+		if err := checkValue(ctx, rw, key, old); err != nil {
+			return err
+		}
+		return rw.Set(ctx, key, bytes.NewReader(data))
+	})
+}
+
+// CompareVersionAndSet updates a key to newValue if the commit version of its
+// current value is equal to the given version. Commit versions of the keys are
+// not visible through the transactions, so there is no fallback and
+// errors.ErrUnsupported is returned for the databases that do not implement
+// the ConditionalWriter interface.
+func CompareVersionAndSet(ctx context.Context, db Database, key string, version int64, newValue io.Reader) error {
+	if cw, ok := db.(ConditionalWriter); ok {
+		return cw.CompareVersionAndSet(ctx, key, version, newValue)
+	}
+	return errors.ErrUnsupported
+}
+
+// SetIfAbsent creates a key with the given value if it doesn't exist. It uses
+// the ConditionalWriter interface when the database implements it and falls
+// back to a transaction with retries otherwise.
+func SetIfAbsent(ctx context.Context, db Database, key string, value io.Reader) error {
+	if cw, ok := db.(ConditionalWriter); ok {
+		return cw.SetIfAbsent(ctx, key, value)
+	}
+	data, err := io.ReadAll(value)
+	if err != nil {
+		return err
+	}
+	return WithReadWriterRetry(ctx, db, nil, func(ctx context.Context, rw ReadWriter) error {
+		if _, err := rw.Get(ctx, key); err == nil {
+			return fmt.Errorf("key %q already exists: %w", key, ErrConditionFailed)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return rw.Set(ctx, key, bytes.NewReader(data))
+	})
+}
+
+// DeleteIfEqual deletes a key if its current value is equal to the given
+// value. It uses the ConditionalWriter interface when the database implements
+// it and falls back to a transaction with retries otherwise.
+func DeleteIfEqual(ctx context.Context, db Database, key string, value io.Reader) error {
+	if cw, ok := db.(ConditionalWriter); ok {
+		return cw.DeleteIfEqual(ctx, key, value)
+	}
+	data, err := io.ReadAll(value)
+	if err != nil {
+		return err
+	}
+	return WithReadWriterRetry(ctx, db, nil, func(ctx context.Context, rw ReadWriter) error {
+		if err := checkValue(ctx, rw, key, data); err != nil {
+			return err
+		}
+		return rw.Delete(ctx, key)
+	})
+}
+
+// checkValue returns an error wrapping ErrConditionFailed if the current value
+// of a key is not equal to the expected value.
+func checkValue(ctx context.Context, r Reader, key string, want []byte) error {
+	v, err := r.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("key %q doesn't exist: %w", key, ErrConditionFailed)
+		}
+		return err
+	}
+	var data []byte
+	if v != nil {
+		if data, err = io.ReadAll(v); err != nil {
+			return err
+		}
+	}
+	if !bytes.Equal(data, want) {
+		return fmt.Errorf("key %q has a different value: %w", key, ErrConditionFailed)
+	}
+	return nil
+}
@@ -2,7 +2,10 @@
 
 package kv
 
-import "context"
+import (
+	"context"
+	"fmt"
+)
 
 type Reader interface {
 	Getter
@@ -42,12 +45,112 @@ type Transaction interface {
 	// the backing key-value store. Returns nil if transaction is committed
 	// successfully.
 	//
+	// Commit returns an error wrapping ErrConflict when the transaction
+	// conflicts with other transactions, in which case, transaction can be
+	// retried.
+	//
 	// Commit may return os.ErrClosed if transaction is already committed or
 	// rolled-back.
 	Commit(ctx context.Context) error
 }
 
+// IsolationLevel identifies the conflict checks performed by a transaction
+// at commit.
+type IsolationLevel int
+
+const (
+	// DefaultIsolation selects the default isolation level of the backend.
+	DefaultIsolation IsolationLevel = iota
+
+	// SnapshotIsolation reads from a consistent snapshot of the database and
+	// checks only for write-write conflicts at commit. Transactions may suffer
+	// from the write-skew anomaly.
+	SnapshotIsolation
+
+	// Serializable validates all keys read or written by a transaction along
+	// with the key ranges observed by Ascend, Descend and Scan operations, so
+	// that transactions appear to execute one after another.
+	Serializable
+)
+
+func (v IsolationLevel) String() string {
+	switch v {
+	case DefaultIsolation:
+		return "default"
+	case SnapshotIsolation:
+		return "snapshot"
+	case Serializable:
+		return "serializable"
+	}
+	return fmt.Sprintf("IsolationLevel(%d)", int(v))
+}
+
 type Database interface {
 	NewTransaction(ctx context.Context) (Transaction, error)
 	NewSnapshot(ctx context.Context) (Snapshot, error)
 }
+
+// TransactionOptions holds optional parameters for creating a transaction.
+type TransactionOptions struct {
+	// ReadOnly when true creates a transaction that cannot modify the
+	// database. Read-only transactions read from a consistent snapshot and
+	// never fail at commit.
+	ReadOnly bool
+
+	// Isolation selects the conflict checks performed by the transaction at
+	// commit. It is ignored for read-only transactions.
+	Isolation IsolationLevel
+}
+
+// TransactionOpener is an optional interface implemented by databases that
+// support creating transactions with options.
+type TransactionOpener interface {
+	// NewTransactionWithOptions is same as Database.NewTransaction, but creates
+	// the transaction as per the input options. Nil options are same as the
+	// default options.
+	NewTransactionWithOptions(ctx context.Context, opts *TransactionOptions) (Transaction, error)
+}
+
+// VersionedDatabase is an optional interface implemented by databases that
+// can open snapshots at older commit versions.
+type VersionedDatabase interface {
+	Database
+
+	// NewSnapshotAt returns a snapshot of the database as it was right after the
+	// commit with the given version. Snapshots returned by this method also
+	// implement `Version() int64` method.
+	//
+	// Returns os.ErrNotExist if data for the version is not retained anymore
+	// and os.ErrInvalid if version is not yet committed.
+	NewSnapshotAt(ctx context.Context, version int64) (Snapshot, error)
+}
+
+// CommitVersioner is an optional interface implemented by snapshots and
+// transactions that expose the commit versions of the database.
+//
+// Commit versions increase with every commit, so they can be used to order
+// the commits or to wait for a database replica to catch up.
+type CommitVersioner interface {
+	// ReadVersion returns the commit version of the data visible to the
+	// snapshot or transaction.
+	ReadVersion() int64
+
+	// CommitVersion returns the commit version assigned to a transaction by a
+	// successful Commit. Returns false if the transaction is not committed or
+	// for snapshots.
+	CommitVersion() (int64, bool)
+}
+
+// Historian is an optional interface implemented by snapshots that can report
+// the older versions of a key.
+type Historian interface {
+	// History returns an iterator over the versions of a key that are still
+	// retained by the database, in the increasing order of their commit
+	// versions. Deleted versions are included as changes with Deleted set to
+	// true. Only the versions visible to the snapshot are reported and the
+	// iterator returns io.EOF after the last version.
+	//
+	// Databases retain the older versions only as necessary, so the oldest
+	// reported version may not be the version that created the key.
+	History(ctx context.Context, key string) (ChangeIterator, error)
+}
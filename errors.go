@@ -0,0 +1,57 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kv
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrConflict is returned (possibly wrapped) by Transaction.Commit when the
+// transaction cannot be committed because of conflicting updates by other
+// transactions. Such transactions can be retried from the beginning, for
+// example, with WithReadWriterRetry.
+var ErrConflict = errors.New("transaction conflict")
+
+// ConflictReason describes how a key was modified by a conflicting
+// transaction.
+type ConflictReason string
+
+const (
+	ConflictCreated ConflictReason = "created"
+	ConflictDeleted ConflictReason = "deleted"
+	ConflictUpdated ConflictReason = "updated"
+)
+
+// ConflictError describes a conflict detected by Transaction.Commit in more
+// detail. It matches ErrConflict with errors.Is, so callers that do not need
+// the details can check for ErrConflict instead.
+//
+// Versions are backend specific numbers, which increase with every commit. A
+// zero version indicates that key did not exist.
+type ConflictError struct {
+	// Key is the conflicting key.
+	Key string
+
+	// Reason tells if the key was created, deleted or updated by the
+	// conflicting transaction.
+	Reason ConflictReason
+
+	// ReadVersion is the version of the key seen by the failed transaction.
+	ReadVersion int64
+
+	// CurrentVersion is the latest committed version of the key, which was
+	// written by the conflicting transaction.
+	CurrentVersion int64
+
+	// TxVersion is the version of the failed transaction.
+	TxVersion int64
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%v: key %q is %s by another transaction (read version %d, current version %d)", ErrConflict, e.Key, e.Reason, e.ReadVersion, e.CurrentVersion)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return ErrConflict
+}
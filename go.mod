@@ -1,6 +1,6 @@
 module github.com/bvkgo/kv
 
-go 1.21
+go 1.23
 
 require golang.org/x/sync v0.4.0
 
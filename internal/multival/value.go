@@ -12,6 +12,10 @@ type Value struct {
 	Version int64
 	Data    []byte
 	Deleted bool
+
+	// ExpiresAt when non-zero holds the expiry time of the value in unix
+	// nanoseconds.
+	ExpiresAt int64
 }
 
 func (v *Value) String() string {
@@ -134,3 +138,42 @@ func (mv *MultiValue) Empty() bool {
 	}
 	return true
 }
+
+// Len returns the number of versions in the multi-value.
+func (mv *MultiValue) Len() int {
+	if mv == nil {
+		return 0
+	}
+	return len(mv.values)
+}
+
+// DataSize returns the total size of the data in all versions.
+func (mv *MultiValue) DataSize() int64 {
+	if mv == nil {
+		return 0
+	}
+	var size int64
+	for _, v := range mv.values {
+		size += int64(len(v.Data))
+	}
+	return size
+}
+
+// Values returns all versions up to and including the given version in the
+// increasing order of versions. Returned values must not be modified.
+func (mv *MultiValue) Values(maxVersion int64) []*Value {
+	if mv == nil {
+		return nil
+	}
+	index, ok := slices.BinarySearchFunc(mv.values, maxVersion, findValue)
+	if ok {
+		index++
+	}
+	return slices.Clone(mv.values[:index])
+}
+
+// Visible returns true if the value is not deleted and is not expired at the
+// given time in unix nanoseconds.
+func (v *Value) Visible(now int64) bool {
+	return !v.Deleted && (v.ExpiresAt == 0 || now < v.ExpiresAt)
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package ordmap
+
+// Cursor represents a position in an immutable version of the map. Cursor
+// can be moved in both directions and can be repositioned with the Seek
+// methods. Cursor is invalid when it is not positioned at any key.
+type Cursor[V any] struct {
+	root *node[V]
+
+	// stack holds the nodes on the path from the root to the current
+	// node. Stack is empty when the cursor is invalid.
+	stack []*node[V]
+}
+
+// Valid returns true if cursor is positioned at a key.
+func (c *Cursor[V]) Valid() bool {
+	return len(c.stack) > 0
+}
+
+// Key returns the key at the current position. Cursor must be valid.
+func (c *Cursor[V]) Key() string {
+	return c.stack[len(c.stack)-1].key
+}
+
+// Value returns the value at the current position. Cursor must be valid.
+func (c *Cursor[V]) Value() V {
+	return c.stack[len(c.stack)-1].value
+}
+
+// Len returns the number of keys in the map version visible to the cursor.
+func (c *Cursor[V]) Len() int {
+	return c.root.count()
+}
+
+// First moves the cursor to the smallest key. Returns false if map is empty.
+func (c *Cursor[V]) First() bool {
+	c.stack = c.stack[:0]
+	for n := c.root; n != nil; n = n.left {
+		c.stack = append(c.stack, n)
+	}
+	return c.Valid()
+}
+
+// Last moves the cursor to the largest key. Returns false if map is empty.
+func (c *Cursor[V]) Last() bool {
+	c.stack = c.stack[:0]
+	for n := c.root; n != nil; n = n.right {
+		c.stack = append(c.stack, n)
+	}
+	return c.Valid()
+}
+
+// SeekGE moves the cursor to the smallest key that is greater than or equal
+// to the input key. Returns false if there is no such key.
+func (c *Cursor[V]) SeekGE(key string) bool {
+	return c.seek(func(k string) bool { return k >= key }, true)
+}
+
+// SeekLE moves the cursor to the largest key that is less than or equal to
+// the input key. Returns false if there is no such key.
+func (c *Cursor[V]) SeekLE(key string) bool {
+	return c.seek(func(k string) bool { return k <= key }, false)
+}
+
+// SeekLT moves the cursor to the largest key that is less than the input
+// key. Returns false if there is no such key.
+func (c *Cursor[V]) SeekLT(key string) bool {
+	return c.seek(func(k string) bool { return k < key }, false)
+}
+
+// seek walks down from the root and stops at the last node on the path that
+// matches the input condition. When ascending is true, keys matching the
+// condition are expected to be on the right side, so walk continues to the
+// left after a match, and vice versa.
+func (c *Cursor[V]) seek(match func(string) bool, ascending bool) bool {
+	c.stack = c.stack[:0]
+	depth := 0
+	for n := c.root; n != nil; {
+		c.stack = append(c.stack, n)
+		ok := match(n.key)
+		if ok {
+			depth = len(c.stack)
+		}
+		if ok == ascending {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	c.stack = c.stack[:depth]
+	return c.Valid()
+}
+
+// Next moves the cursor to the next larger key. Returns false and makes the
+// cursor invalid if there is no such key.
+func (c *Cursor[V]) Next() bool {
+	if !c.Valid() {
+		return false
+	}
+	if n := c.stack[len(c.stack)-1]; n.right != nil {
+		for n = n.right; n != nil; n = n.left {
+			c.stack = append(c.stack, n)
+		}
+		return true
+	}
+	for {
+		child := c.stack[len(c.stack)-1]
+		c.stack = c.stack[:len(c.stack)-1]
+		if !c.Valid() {
+			return false
+		}
+		if c.stack[len(c.stack)-1].left == child {
+			return true
+		}
+	}
+}
+
+// Prev moves the cursor to the previous smaller key. Returns false and makes
+// the cursor invalid if there is no such key.
+func (c *Cursor[V]) Prev() bool {
+	if !c.Valid() {
+		return false
+	}
+	if n := c.stack[len(c.stack)-1]; n.left != nil {
+		for n = n.left; n != nil; n = n.right {
+			c.stack = append(c.stack, n)
+		}
+		return true
+	}
+	for {
+		child := c.stack[len(c.stack)-1]
+		c.stack = c.stack[:len(c.stack)-1]
+		if !c.Valid() {
+			return false
+		}
+		if c.stack[len(c.stack)-1].right == child {
+			return true
+		}
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+// Package ordmap implements a concurrent map with string keys that is ordered
+// by the keys.
+//
+// Map is implemented as an immutable (copy-on-write) treap. Every update
+// creates a new version of the tree by copying the nodes on the path to the
+// updated key, so readers can access the tree without any locks and can
+// iterate over a consistent version of the tree while updates are in
+// progress. Updates are serialized with a mutex.
+package ordmap
+
+import (
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+)
+
+var seed = maphash.MakeSeed()
+
+type node[V any] struct {
+	key   string
+	value V
+
+	// priority orders the nodes as a max-heap, which keeps the tree balanced
+	// with a high probability.
+	priority uint64
+
+	// size holds number of nodes in the subtree rooted at this node.
+	size int
+
+	left, right *node[V]
+}
+
+func (n *node[V]) update() {
+	n.size = 1 + n.left.count() + n.right.count()
+}
+
+func (n *node[V]) count() int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+// Map is an ordered map with string keys. Zero value is an empty map ready to
+// use. Map must not be copied after first use.
+type Map[V comparable] struct {
+	mu sync.Mutex
+
+	root atomic.Pointer[node[V]]
+}
+
+// Len returns number of keys in the map.
+func (m *Map[V]) Len() int {
+	return m.root.Load().count()
+}
+
+func (m *Map[V]) Load(key string) (value V, ok bool) {
+	for n := m.root.Load(); n != nil; {
+		switch {
+		case key < n.key:
+			n = n.left
+		case key > n.key:
+			n = n.right
+		default:
+			return n.value, true
+		}
+	}
+	return value, false
+}
+
+func (m *Map[V]) Store(key string, value V) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.root.Store(insert(m.root.Load(), key, value, maphash.String(seed, key)))
+}
+
+func (m *Map[V]) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if root, ok := remove(m.root.Load(), key); ok {
+		m.root.Store(root)
+	}
+}
+
+func (m *Map[V]) LoadAndDelete(key string) (value V, loaded bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if value, loaded = m.Load(key); loaded {
+		root, _ := remove(m.root.Load(), key)
+		m.root.Store(root)
+	}
+	return value, loaded
+}
+
+func (m *Map[V]) LoadOrStore(key string, value V) (actual V, loaded bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if actual, loaded = m.Load(key); loaded {
+		return actual, true
+	}
+	m.root.Store(insert(m.root.Load(), key, value, maphash.String(seed, key)))
+	return value, false
+}
+
+func (m *Map[V]) CompareAndDelete(key string, old V) (deleted bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if v, ok := m.Load(key); !ok || v != old {
+		return false
+	}
+	root, _ := remove(m.root.Load(), key)
+	m.root.Store(root)
+	return true
+}
+
+func (m *Map[V]) CompareAndSwap(key string, old, new V) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if v, ok := m.Load(key); !ok || v != old {
+		return false
+	}
+	m.root.Store(insert(m.root.Load(), key, new, maphash.String(seed, key)))
+	return true
+}
+
+// Range calls f for every key-value pair in the ascending order of the keys
+// till f returns false. Range visits a consistent version of the map, so
+// updates performed during the Range are not visible to it.
+func (m *Map[V]) Range(f func(key string, value V) bool) {
+	c := m.Cursor()
+	for ok := c.First(); ok; ok = c.Next() {
+		if !f(c.Key(), c.Value()) {
+			return
+		}
+	}
+}
+
+// Cursor returns an unpositioned cursor over the current version of the
+// map. Updates performed after the Cursor call are not visible to the cursor.
+func (m *Map[V]) Cursor() *Cursor[V] {
+	return &Cursor[V]{root: m.root.Load()}
+}
+
+func insert[V any](n *node[V], key string, value V, priority uint64) *node[V] {
+	if n == nil {
+		return &node[V]{key: key, value: value, priority: priority, size: 1}
+	}
+
+	c := *n
+	switch {
+	case key < n.key:
+		c.left = insert(n.left, key, value, priority)
+		if c.left.priority > c.priority {
+			return rotateRight(&c)
+		}
+	case key > n.key:
+		c.right = insert(n.right, key, value, priority)
+		if c.right.priority > c.priority {
+			return rotateLeft(&c)
+		}
+	default:
+		c.value = value
+	}
+	c.update()
+	return &c
+}
+
+// rotateRight and rotateLeft modify the input nodes in-place, so they must be
+// called only on newly created nodes.
+
+func rotateRight[V any](n *node[V]) *node[V] {
+	l := n.left
+	n.left = l.right
+	n.update()
+	l.right = n
+	l.update()
+	return l
+}
+
+func rotateLeft[V any](n *node[V]) *node[V] {
+	r := n.right
+	n.right = r.left
+	n.update()
+	r.left = n
+	r.update()
+	return r
+}
+
+func remove[V any](n *node[V], key string) (*node[V], bool) {
+	if n == nil {
+		return nil, false
+	}
+
+	switch {
+	case key < n.key:
+		l, ok := remove(n.left, key)
+		if !ok {
+			return n, false
+		}
+		c := *n
+		c.left = l
+		c.update()
+		return &c, true
+	case key > n.key:
+		r, ok := remove(n.right, key)
+		if !ok {
+			return n, false
+		}
+		c := *n
+		c.right = r
+		c.update()
+		return &c, true
+	default:
+		return join(n.left, n.right), true
+	}
+}
+
+// join merges two trees where all keys in a are smaller than the keys in b.
+func join[V any](a, b *node[V]) *node[V] {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if a.priority > b.priority {
+		c := *a
+		c.right = join(a.right, b)
+		c.update()
+		return &c
+	}
+	c := *b
+	c.left = join(a, b.left)
+	c.update()
+	return &c
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package ordmap
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestMap(t *testing.T) {
+	var m Map[int]
+	ref := make(map[string]int)
+
+	for i := 0; i < 20000; i++ {
+		key := fmt.Sprintf("%04d", rand.Intn(2000))
+		switch rand.Intn(4) {
+		case 0, 1:
+			m.Store(key, i)
+			ref[key] = i
+		case 2:
+			m.Delete(key)
+			delete(ref, key)
+		case 3:
+			v, ok := m.Load(key)
+			if rv, rok := ref[key]; ok != rok || v != rv {
+				t.Fatalf("key %s: want %d/%t, got %d/%t", key, rv, rok, v, ok)
+			}
+		}
+	}
+
+	if m.Len() != len(ref) {
+		t.Fatalf("want %d keys, got %d", len(ref), m.Len())
+	}
+
+	var keys []string
+	for k := range ref {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var got []string
+	m.Range(func(k string, v int) bool {
+		if ref[k] != v {
+			t.Fatalf("key %s: want %d, got %d", k, ref[k], v)
+		}
+		got = append(got, k)
+		return true
+	})
+	if !slices.Equal(keys, got) {
+		t.Fatalf("unexpected key order")
+	}
+
+	c := m.Cursor()
+	var rgot []string
+	for ok := c.Last(); ok; ok = c.Prev() {
+		rgot = append(rgot, c.Key())
+	}
+	slices.Reverse(rgot)
+	if !slices.Equal(keys, rgot) {
+		t.Fatalf("unexpected reverse key order")
+	}
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("%04d", rand.Intn(2100))
+		ge, _ := slices.BinarySearch(keys, key)
+		if ok := c.SeekGE(key); ok != (ge < len(keys)) || (ok && c.Key() != keys[ge]) {
+			t.Fatalf("SeekGE(%s) returned wrong position", key)
+		}
+		if ge > 0 {
+			if ok := c.SeekLT(key); !ok || c.Key() != keys[ge-1] {
+				t.Fatalf("SeekLT(%s) returned wrong position", key)
+			}
+		} else if c.SeekLT(key) {
+			t.Fatalf("SeekLT(%s) must fail", key)
+		}
+		le := ge - 1
+		if ge < len(keys) && keys[ge] == key {
+			le = ge
+		}
+		if ok := c.SeekLE(key); ok != (le >= 0) || (ok && c.Key() != keys[le]) {
+			t.Fatalf("SeekLE(%s) returned wrong position", key)
+		}
+	}
+}
+
+func TestCursorIsolation(t *testing.T) {
+	var m Map[int]
+	for i := 0; i < 100; i++ {
+		m.Store(fmt.Sprintf("%03d", i), i)
+	}
+
+	c := m.Cursor()
+	for i := 0; i < 100; i += 2 {
+		m.Delete(fmt.Sprintf("%03d", i))
+	}
+	m.Store("100", 100)
+
+	count := 0
+	for ok := c.First(); ok; ok = c.Next() {
+		if c.Key() != fmt.Sprintf("%03d", count) {
+			t.Fatalf("want key %03d, got %s", count, c.Key())
+		}
+		count++
+	}
+	if count != 100 || c.Len() != 100 {
+		t.Fatalf("want 100 keys, got %d", count)
+	}
+	if m.Len() != 51 {
+		t.Fatalf("want 51 keys, got %d", m.Len())
+	}
+}
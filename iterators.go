@@ -0,0 +1,309 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kv
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// Iterators returned by the functions in this file follow the Iterator
+// contract: they return io.EOF at the end and retain the first error from the
+// input iterators for all further calls. Closing them closes the input
+// iterators.
+
+// Filter returns an iterator over the key-value pairs from the input iterator
+// whose keys are accepted by the keep function.
+func Filter(it Iterator, keep func(key string) bool) Iterator {
+	return &filterIterator{it: it, keep: keep}
+}
+
+type filterIterator struct {
+	it   Iterator
+	keep func(string) bool
+	err  error
+}
+
+func (f *filterIterator) Fetch(ctx context.Context, next bool) (string, io.Reader, error) {
+	if f.err != nil {
+		return "", nil, f.err
+	}
+	k, v, err := f.it.Fetch(ctx, next)
+	for err == nil && !f.keep(k) {
+		k, v, err = f.it.Fetch(ctx, true)
+	}
+	if err != nil {
+		f.err = err
+		return "", nil, err
+	}
+	return k, v, nil
+}
+
+func (f *filterIterator) Close() error {
+	return Close(f.it)
+}
+
+// MapKeys returns an iterator that replaces the keys from the input iterator
+// with the result of the mapping function. Mapping function is expected to
+// preserve the key order when the result is used with Merge.
+func MapKeys(it Iterator, mapping func(key string) string) Iterator {
+	return &mapIterator{it: it, mapping: mapping}
+}
+
+type mapIterator struct {
+	it      Iterator
+	mapping func(string) string
+}
+
+func (m *mapIterator) Fetch(ctx context.Context, next bool) (string, io.Reader, error) {
+	k, v, err := m.it.Fetch(ctx, next)
+	if err != nil {
+		return "", nil, err
+	}
+	return m.mapping(k), v, nil
+}
+
+func (m *mapIterator) Close() error {
+	return Close(m.it)
+}
+
+// Limit returns an iterator over the first n key-value pairs from the input
+// iterator.
+func Limit(it Iterator, n int) Iterator {
+	return &limitIterator{it: it, n: n}
+}
+
+type limitIterator struct {
+	it  Iterator
+	n   int
+	pos int
+	err error
+}
+
+func (l *limitIterator) Fetch(ctx context.Context, next bool) (string, io.Reader, error) {
+	if l.err != nil {
+		return "", nil, l.err
+	}
+	if next {
+		l.pos++
+	}
+	if l.pos >= l.n {
+		l.err = io.EOF
+		return "", nil, l.err
+	}
+	k, v, err := l.it.Fetch(ctx, next)
+	if err != nil {
+		l.err = err
+		return "", nil, err
+	}
+	return k, v, nil
+}
+
+func (l *limitIterator) Close() error {
+	return Close(l.it)
+}
+
+// Skip returns an iterator that skips the first n key-value pairs from the
+// input iterator.
+func Skip(it Iterator, n int) Iterator {
+	return &skipIterator{it: it, n: n}
+}
+
+type skipIterator struct {
+	it  Iterator
+	n   int
+	err error
+}
+
+func (s *skipIterator) Fetch(ctx context.Context, next bool) (string, io.Reader, error) {
+	if s.err != nil {
+		return "", nil, s.err
+	}
+	if s.n > 0 {
+		_, _, err := s.it.Fetch(ctx, false)
+		for ; err == nil && s.n > 0; s.n-- {
+			_, _, err = s.it.Fetch(ctx, true)
+		}
+		if err != nil {
+			s.err = err
+			return "", nil, err
+		}
+	}
+	k, v, err := s.it.Fetch(ctx, next)
+	if err != nil {
+		s.err = err
+		return "", nil, err
+	}
+	return k, v, nil
+}
+
+func (s *skipIterator) Close() error {
+	return Close(s.it)
+}
+
+// Concat returns an iterator over the key-value pairs from all input
+// iterators, one after another.
+func Concat(its ...Iterator) Iterator {
+	return &concatIterator{its: its}
+}
+
+type concatIterator struct {
+	its []Iterator
+	i   int
+	err error
+}
+
+func (c *concatIterator) Fetch(ctx context.Context, next bool) (string, io.Reader, error) {
+	if c.err != nil {
+		return "", nil, c.err
+	}
+	for ; c.i < len(c.its); c.i++ {
+		k, v, err := c.its[c.i].Fetch(ctx, next)
+		if err == nil {
+			return k, v, nil
+		}
+		if !errors.Is(err, io.EOF) {
+			c.err = err
+			return "", nil, err
+		}
+		// First key-value pair of the next iterator is the next position.
+		next = false
+	}
+	c.err = io.EOF
+	return "", nil, c.err
+}
+
+func (c *concatIterator) Close() error {
+	for _, it := range c.its {
+		Close(it)
+	}
+	return nil
+}
+
+// Merge returns an iterator that merges the key-value pairs from multiple
+// ordered iterators, for example, from the same range of different snapshots.
+// Input iterators must all be in the ascending order or all be in the
+// descending order as indicated by the descending parameter. When a key is
+// present in multiple iterators, only the key-value pair from the earliest
+// iterator in the argument order is returned.
+func Merge(descending bool, its ...Iterator) Iterator {
+	return &mergeIterator{
+		its:        its,
+		descending: descending,
+		keys:       make([]string, len(its)),
+		values:     make([]io.Reader, len(its)),
+		valid:      make([]bool, len(its)),
+	}
+}
+
+type mergeIterator struct {
+	its        []Iterator
+	descending bool
+
+	// keys and values hold the current key-value pair of each input iterator
+	// when the corresponding valid entry is true.
+	keys   []string
+	values []io.Reader
+	valid  []bool
+
+	started bool
+	err     error
+}
+
+func (m *mergeIterator) Fetch(ctx context.Context, next bool) (string, io.Reader, error) {
+	if m.err != nil {
+		return "", nil, m.err
+	}
+	if !m.started {
+		m.started = true
+		for i := range m.its {
+			if err := m.fetch(ctx, i, false); err != nil {
+				m.err = err
+				return "", nil, err
+			}
+		}
+	}
+	if next {
+		if cur, ok := m.current(); ok {
+			key := m.keys[cur]
+			for i := range m.its {
+				if m.valid[i] && m.keys[i] == key {
+					if err := m.fetch(ctx, i, true); err != nil {
+						m.err = err
+						return "", nil, err
+					}
+				}
+			}
+		}
+	}
+	cur, ok := m.current()
+	if !ok {
+		m.err = io.EOF
+		return "", nil, m.err
+	}
+	return m.keys[cur], m.values[cur], nil
+}
+
+// fetch updates the current key-value pair of an input iterator.
+func (m *mergeIterator) fetch(ctx context.Context, i int, next bool) error {
+	k, v, err := m.its[i].Fetch(ctx, next)
+	if err != nil {
+		m.valid[i], m.values[i] = false, nil
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return err
+	}
+	m.keys[i], m.values[i], m.valid[i] = k, v, true
+	return nil
+}
+
+// current returns the index of the input iterator with the smallest (or the
+// largest when descending) current key.
+func (m *mergeIterator) current() (int, bool) {
+	cur := -1
+	for i := range m.its {
+		if !m.valid[i] {
+			continue
+		}
+		if cur < 0 || (!m.descending && m.keys[i] < m.keys[cur]) || (m.descending && m.keys[i] > m.keys[cur]) {
+			cur = i
+		}
+	}
+	return cur, cur >= 0
+}
+
+func (m *mergeIterator) Close() error {
+	for _, it := range m.its {
+		Close(it)
+	}
+	return nil
+}
+
+// Prefix returns an iterator over all keys with the given prefix in ascending
+// order.
+func Prefix(ctx context.Context, r Ranger, prefix string) (Iterator, error) {
+	return AscendRange(ctx, r, PrefixRange(prefix))
+}
+
+// Collect reads all key-value pairs from an iterator. Values are nil for the
+// iterators that do not return values, like the keys-only iterators.
+func Collect(ctx context.Context, it Iterator) ([]string, [][]byte, error) {
+	var keys []string
+	var values [][]byte
+	for k, v, err := it.Fetch(ctx, false); err == nil; k, v, err = it.Fetch(ctx, true) {
+		var data []byte
+		if v != nil {
+			if data, err = io.ReadAll(v); err != nil {
+				return nil, nil, err
+			}
+		}
+		keys = append(keys, k)
+		values = append(values, data)
+	}
+	if _, _, err := it.Fetch(ctx, false); err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, err
+	}
+	return keys, values, nil
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kv_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bvkgo/kv"
+	"github.com/bvkgo/kv/kvmemdb"
+)
+
+// newSnapshot returns a snapshot of a new database with the given keys. Value
+// of each key is the tag followed by the key.
+func newSnapshot(t *testing.T, tag string, keys ...string) kv.Snapshot {
+	ctx := context.Background()
+	db := kvmemdb.New()
+	set := func(ctx context.Context, rw kv.ReadWriter) error {
+		for _, k := range keys {
+			if err := rw.Set(ctx, k, strings.NewReader(tag+k)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := kv.WithReadWriter(ctx, db, set); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := db.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { snap.Discard(ctx) })
+	return snap
+}
+
+func ascend(t *testing.T, r kv.Reader, begin, end string) kv.Iterator {
+	it, err := r.Ascend(context.Background(), begin, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return it
+}
+
+func collect(t *testing.T, it kv.Iterator) string {
+	t.Helper()
+	keys, values, err := kv.Collect(context.Background(), it)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kvs []string
+	for i := range keys {
+		kvs = append(kvs, keys[i]+"="+string(values[i]))
+	}
+	return strings.Join(kvs, ",")
+}
+
+func TestCombinators(t *testing.T) {
+	ctx := context.Background()
+	a := newSnapshot(t, "a", "k1", "k2", "k3", "k4", "x1")
+	b := newSnapshot(t, "b", "k2", "k5", "x0")
+
+	notK3 := func(k string) bool { return k != "k3" }
+	tests := []struct {
+		name string
+		it   kv.Iterator
+		want string
+	}{
+		{"filter", kv.Filter(ascend(t, a, "", ""), notK3), "k1=ak1,k2=ak2,k4=ak4,x1=ax1"},
+		{"map-keys", kv.MapKeys(ascend(t, b, "", "k9"), strings.ToUpper), "K2=bk2,K5=bk5"},
+		{"limit", kv.Limit(ascend(t, a, "", ""), 2), "k1=ak1,k2=ak2"},
+		{"limit-zero", kv.Limit(ascend(t, a, "", ""), 0), ""},
+		{"skip", kv.Skip(ascend(t, a, "", ""), 3), "k4=ak4,x1=ax1"},
+		{"skip-all", kv.Skip(ascend(t, a, "", ""), 10), ""},
+		{"skip-limit", kv.Limit(kv.Skip(ascend(t, a, "", ""), 1), 2), "k2=ak2,k3=ak3"},
+		{"concat", kv.Concat(ascend(t, b, "x", ""), ascend(t, a, "", "k2"), ascend(t, a, "z", "")), "x0=bx0,k1=ak1"},
+		{"merge", kv.Merge(false, ascend(t, a, "", ""), ascend(t, b, "", "")), "k1=ak1,k2=ak2,k3=ak3,k4=ak4,k5=bk5,x0=bx0,x1=ax1"},
+		{"merge-priority", kv.Merge(false, ascend(t, b, "", "x"), ascend(t, a, "", "k3")), "k1=ak1,k2=bk2,k5=bk5"},
+	}
+	for _, test := range tests {
+		if got := collect(t, test.it); got != test.want {
+			t.Errorf("%s: want %s, got %s", test.name, test.want, got)
+		}
+		if _, _, err := test.it.Fetch(ctx, true); !errors.Is(err, io.EOF) {
+			t.Errorf("%s: want sticky EOF, got %v", test.name, err)
+		}
+		kv.Close(test.it)
+	}
+
+	da, err := a.Descend(ctx, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := b.Descend(ctx, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := collect(t, kv.Merge(true, da, db)), "x1=ax1,x0=bx0,k5=bk5,k4=ak4,k3=ak3,k2=ak2,k1=ak1"; got != want {
+		t.Fatalf("descending merge: want %s, got %s", want, got)
+	}
+
+	it, err := kv.Prefix(ctx, a, "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := collect(t, it), "k1=ak1,k2=ak2,k3=ak3,k4=ak4"; got != want {
+		t.Fatalf("prefix: want %s, got %s", want, got)
+	}
+}
+
+// errIterator returns a few keys followed by an error.
+type errIterator struct {
+	keys []string
+	pos  int
+}
+
+func (e *errIterator) Fetch(ctx context.Context, next bool) (string, io.Reader, error) {
+	if next {
+		e.pos++
+	}
+	if e.pos >= len(e.keys) {
+		return "", nil, os.ErrClosed
+	}
+	return e.keys[e.pos], strings.NewReader(e.keys[e.pos]), nil
+}
+
+func TestCombinatorErrors(t *testing.T) {
+	ctx := context.Background()
+	a := newSnapshot(t, "a", "k1", "k2")
+
+	keep := func(string) bool { return true }
+	for name, it := range map[string]kv.Iterator{
+		"filter": kv.Filter(&errIterator{keys: []string{"k1"}}, keep),
+		"skip":   kv.Skip(&errIterator{keys: []string{"k1"}}, 2),
+		"concat": kv.Concat(ascend(t, a, "", ""), &errIterator{}),
+		"merge":  kv.Merge(false, ascend(t, a, "", ""), &errIterator{keys: []string{"k0"}}),
+	} {
+		if _, _, err := kv.Collect(ctx, it); !errors.Is(err, os.ErrClosed) {
+			t.Fatalf("%s: want ErrClosed, got %v", name, err)
+		}
+		// Errors are retained by the iterators.
+		if _, _, err := it.Fetch(ctx, false); !errors.Is(err, os.ErrClosed) {
+			t.Fatalf("%s: want sticky ErrClosed, got %v", name, err)
+		}
+	}
+}
+
+func TestAllRange(t *testing.T) {
+	ctx := context.Background()
+	a := newSnapshot(t, "a", "k1", "k2", "k3")
+
+	var keys []string
+	seq, errf := kv.Range(ctx, a, "k2", "")
+	for k, v := range seq {
+		data, err := io.ReadAll(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, k+"="+string(data))
+	}
+	if err := errf(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(keys, ","), "k2=ak2,k3=ak3"; got != want {
+		t.Fatalf("want %s, got %s", want, got)
+	}
+
+	// Breaking out of the loop is not an error.
+	seq, errf = kv.All(ctx, ascend(t, a, "", ""))
+	for k := range seq {
+		if k == "k2" {
+			break
+		}
+	}
+	if err := errf(); err != nil {
+		t.Fatal(err)
+	}
+
+	seq, errf = kv.All(ctx, &errIterator{keys: []string{"k1"}})
+	n := 0
+	for range seq {
+		n++
+	}
+	if err := errf(); n != 1 || !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("want one key and ErrClosed, got %d, %v", n, err)
+	}
+
+	seq, errf = kv.Range(ctx, a, "k2", "k1")
+	for range seq {
+		t.Fatalf("want no keys for an invalid range")
+	}
+	if err := errf(); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("want ErrInvalid, got %v", err)
+	}
+}
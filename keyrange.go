@@ -0,0 +1,188 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kv
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// KeyRange represents the keys that are greater than or equal to Begin and
+// lesser than End, with the same conventions as the Ranger interface: an
+// empty Begin has no lower limit and an empty End has no upper limit.
+//
+// Ranges with a non-empty End that is not greater than the Begin are empty.
+type KeyRange struct {
+	Begin, End string
+}
+
+// PrefixRange returns the range of all keys with the given prefix.
+func PrefixRange(prefix string) KeyRange {
+	return KeyRange{Begin: prefix, End: PrefixEnd(prefix)}
+}
+
+// PrefixEnd returns the smallest key that is greater than all keys with the
+// given prefix, which is the exclusive end for a prefix scan. Returns the
+// empty string, which means no upper limit, if there is no such key.
+func PrefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	return ""
+}
+
+// IsEmpty returns true if the range cannot contain any key.
+func (r KeyRange) IsEmpty() bool {
+	return r.End != "" && r.Begin >= r.End
+}
+
+// Contains returns true if the key is in the range.
+func (r KeyRange) Contains(key string) bool {
+	return key >= r.Begin && (r.End == "" || key < r.End)
+}
+
+// Intersect returns the keys that are in both ranges. Result may be empty.
+func (r KeyRange) Intersect(other KeyRange) KeyRange {
+	result := r
+	if other.Begin > result.Begin {
+		result.Begin = other.Begin
+	}
+	if endLess(other.End, result.End) {
+		result.End = other.End
+	}
+	return result
+}
+
+// Split divides the range into at most n contiguous, non-overlapping ranges
+// of roughly equal key space, which can be scanned in parallel. Split points
+// are computed from the range boundaries, not from the keys in the database,
+// so the number of keys in each range can vary. Empty ranges are not split.
+func (r KeyRange) Split(n int) []KeyRange {
+	if n <= 1 || r.IsEmpty() {
+		return []KeyRange{r}
+	}
+
+	// Treat the keys as fixed width numbers with enough extra bytes to have
+	// distinct values for all split points. Span can still be zero when the
+	// boundaries differ only in trailing zero bytes, in which case all split
+	// points are skipped below.
+	width := max(len(r.Begin), len(r.End)) + 8
+	begin := keyToInt(r.Begin, width)
+	end := new(big.Int).Lsh(big.NewInt(1), uint(8*width))
+	if r.End != "" {
+		end = keyToInt(r.End, width)
+	}
+	span := new(big.Int).Sub(end, begin)
+
+	var ranges []KeyRange
+	last := r.Begin
+	for i := 1; i < n; i++ {
+		p := new(big.Int).Mul(span, big.NewInt(int64(i)))
+		p.Quo(p, big.NewInt(int64(n)))
+		p.Add(p, begin)
+
+		// Trailing zero bytes are dropped for readability, which can only move
+		// the split point closer to the previous one.
+		key := strings.TrimRight(intToKey(p, width), "\x00")
+		if key <= last || (r.End != "" && key >= r.End) {
+			continue
+		}
+		ranges = append(ranges, KeyRange{Begin: last, End: key})
+		last = key
+	}
+	return append(ranges, KeyRange{Begin: last, End: r.End})
+}
+
+// keyToInt returns the key as a big-endian number of the given width after
+// padding it with zero bytes.
+func keyToInt(key string, width int) *big.Int {
+	buf := make([]byte, width)
+	copy(buf, key)
+	return new(big.Int).SetBytes(buf)
+}
+
+// intToKey is the inverse of keyToInt.
+func intToKey(v *big.Int, width int) string {
+	buf := make([]byte, width)
+	return string(v.FillBytes(buf))
+}
+
+// endLess returns true if range end a is lesser than the range end b, where
+// empty ends are greater than all keys.
+func endLess(a, b string) bool {
+	if a == "" {
+		return false
+	}
+	return b == "" || a < b
+}
+
+// AscendRange returns the key-value pairs in the range in ascending order.
+// Empty ranges return an iterator without any keys.
+func AscendRange(ctx context.Context, r Ranger, kr KeyRange) (Iterator, error) {
+	if kr.IsEmpty() {
+		// Concat without any input iterators returns io.EOF immediately.
+		return Concat(), nil
+	}
+	return r.Ascend(ctx, kr.Begin, kr.End)
+}
+
+// DescendRange returns the key-value pairs in the range in descending order.
+// Empty ranges return an iterator without any keys.
+func DescendRange(ctx context.Context, r Ranger, kr KeyRange) (Iterator, error) {
+	if kr.IsEmpty() {
+		return Concat(), nil
+	}
+	return r.Descend(ctx, kr.Begin, kr.End)
+}
+
+// RangeSet represents a union of key ranges. Zero value is an empty set.
+type RangeSet struct {
+	// ranges holds non-empty, non-overlapping and non-adjacent ranges in the
+	// increasing order.
+	ranges []KeyRange
+}
+
+// Add adds the keys in a range to the set. Empty ranges are ignored.
+func (s *RangeSet) Add(r KeyRange) {
+	if r.IsEmpty() {
+		return
+	}
+	// Find the first range that ends at or after the new range begin, which is
+	// the first range that may overlap or touch the new range.
+	i := sort.Search(len(s.ranges), func(i int) bool {
+		return s.ranges[i].End == "" || s.ranges[i].End >= r.Begin
+	})
+	j := i
+	for ; j < len(s.ranges); j++ {
+		if r.End != "" && s.ranges[j].Begin > r.End {
+			break
+		}
+		if s.ranges[j].Begin < r.Begin {
+			r.Begin = s.ranges[j].Begin
+		}
+		if endLess(r.End, s.ranges[j].End) {
+			r.End = s.ranges[j].End
+		}
+	}
+	s.ranges = append(s.ranges[:i], append([]KeyRange{r}, s.ranges[j:]...)...)
+}
+
+// Contains returns true if the key is in any of the ranges in the set.
+func (s *RangeSet) Contains(key string) bool {
+	i := sort.Search(len(s.ranges), func(i int) bool {
+		return s.ranges[i].End == "" || key < s.ranges[i].End
+	})
+	return i < len(s.ranges) && s.ranges[i].Contains(key)
+}
+
+// Ranges returns the ranges in the set in the increasing order. Overlapping
+// and adjacent ranges added to the set are combined into a single range.
+func (s *RangeSet) Ranges() []KeyRange {
+	return append([]KeyRange(nil), s.ranges...)
+}
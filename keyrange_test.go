@@ -0,0 +1,234 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kv_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bvkgo/kv"
+)
+
+// randomKey returns a short key from a small alphabet, so that random keys
+// and ranges overlap frequently.
+func randomKey(r *rand.Rand) string {
+	const alphabet = "ab\x00\xff"
+	var sb strings.Builder
+	for i, n := 0, 1+r.Intn(4); i < n; i++ {
+		sb.WriteByte(alphabet[r.Intn(len(alphabet))])
+	}
+	return sb.String()
+}
+
+// randomRange returns a random key range, which may be empty or unbounded.
+func randomRange(r *rand.Rand) kv.KeyRange {
+	var kr kv.KeyRange
+	if r.Intn(4) != 0 {
+		kr.Begin = randomKey(r)
+	}
+	if r.Intn(4) != 0 {
+		kr.End = randomKey(r)
+	}
+	return kr
+}
+
+// strictRanger rejects the ranges that are not valid as per the kv.Ranger
+// interface, which kvmemdb accepts when begin is equal to end.
+type strictRanger struct {
+	kv.Ranger
+}
+
+func (s strictRanger) Ascend(ctx context.Context, begin, end string) (kv.Iterator, error) {
+	if end != "" && begin >= end {
+		return nil, os.ErrInvalid
+	}
+	return s.Ranger.Ascend(ctx, begin, end)
+}
+
+func (s strictRanger) Descend(ctx context.Context, begin, end string) (kv.Iterator, error) {
+	if end != "" && begin >= end {
+		return nil, os.ErrInvalid
+	}
+	return s.Ranger.Descend(ctx, begin, end)
+}
+
+func TestPrefixEnd(t *testing.T) {
+	tests := []struct {
+		prefix, want string
+	}{
+		{"", ""},
+		{"a", "b"},
+		{"ab", "ac"},
+		{"a\xff", "b"},
+		{"a\xff\xff", "b"},
+		{"\xff", ""},
+		{"\xff\xff", ""},
+	}
+	for _, test := range tests {
+		if got := kv.PrefixEnd(test.prefix); got != test.want {
+			t.Errorf("prefix %q: want %q, got %q", test.prefix, test.want, got)
+		}
+	}
+}
+
+func TestKeyRange(t *testing.T) {
+	ctx := context.Background()
+	r := rand.New(rand.NewSource(1))
+
+	keys := make([]string, 0, 64)
+	for len(keys) < cap(keys) {
+		if k := randomKey(r); !slices.Contains(keys, k) {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	snap := strictRanger{newSnapshot(t, "", keys...)}
+
+	for i := 0; i < 500; i++ {
+		a, b := randomRange(r), randomRange(r)
+		name := fmt.Sprintf("%q", a)
+
+		var want []string
+		for _, k := range keys {
+			if a.Contains(k) {
+				want = append(want, k)
+			}
+		}
+		if a.IsEmpty() && len(want) != 0 {
+			t.Fatalf("%s: empty range contains %q", name, want)
+		}
+
+		it, err := kv.AscendRange(ctx, snap, a)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, _, err := kv.Collect(ctx, it)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kv.Close(it)
+		if !slices.Equal(got, want) {
+			t.Fatalf("%s: ascend: want %q, got %q", name, want, got)
+		}
+
+		it, err = kv.DescendRange(ctx, snap, a)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, _, err = kv.Collect(ctx, it)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kv.Close(it)
+		slices.Reverse(got)
+		if !slices.Equal(got, want) {
+			t.Fatalf("%s: descend: want %q, got %q", name, want, got)
+		}
+
+		c := a.Intersect(b)
+		for _, k := range keys {
+			if got, want := c.Contains(k), a.Contains(k) && b.Contains(k); got != want {
+				t.Fatalf("%s intersect %q: key %q: want %t, got %t", name, b, k, want, got)
+			}
+		}
+
+		p := randomKey(r)[:1]
+		for _, k := range keys {
+			if got, want := kv.PrefixRange(p).Contains(k), strings.HasPrefix(k, p); got != want {
+				t.Fatalf("prefix %q: key %q: want %t, got %t", p, k, want, got)
+			}
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 500; i++ {
+		kr := randomRange(r)
+		if kr.IsEmpty() {
+			continue
+		}
+		n := 1 + r.Intn(8)
+		name := fmt.Sprintf("%q/%d", kr, n)
+
+		parts := kr.Split(n)
+		if len(parts) == 0 || len(parts) > n {
+			t.Fatalf("%s: want at most %d ranges, got %q", name, n, parts)
+		}
+		if parts[0].Begin != kr.Begin || parts[len(parts)-1].End != kr.End {
+			t.Fatalf("%s: split does not cover the range: %q", name, parts)
+		}
+		for j, p := range parts {
+			if p.IsEmpty() {
+				t.Fatalf("%s: split has an empty range: %q", name, parts)
+			}
+			if j > 0 && parts[j-1].End != p.Begin {
+				t.Fatalf("%s: split is not contiguous: %q", name, parts)
+			}
+		}
+		for j := 0; j < 20; j++ {
+			k := randomKey(r)
+			count := 0
+			for _, p := range parts {
+				if p.Contains(k) {
+					count++
+				}
+			}
+			want := 0
+			if kr.Contains(k) {
+				want = 1
+			}
+			if count != want {
+				t.Fatalf("%s: key %q: want in %d ranges, got %d", name, k, want, count)
+			}
+		}
+	}
+
+	// Unbounded ranges have enough key space for all split points.
+	if parts := (kv.KeyRange{}).Split(4); len(parts) != 4 {
+		t.Fatalf("want 4 ranges, got %q", parts)
+	}
+}
+
+func TestRangeSet(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 200; i++ {
+		var set kv.RangeSet
+		var added []kv.KeyRange
+		for j, n := 0, r.Intn(8); j < n; j++ {
+			kr := randomRange(r)
+			set.Add(kr)
+			added = append(added, kr)
+		}
+
+		ranges := set.Ranges()
+		for j, kr := range ranges {
+			if kr.IsEmpty() {
+				t.Fatalf("%q: set has an empty range", ranges)
+			}
+			// Adjacent ranges must be combined, so every range must begin after
+			// the previous one ends.
+			if j > 0 && (ranges[j-1].End == "" || ranges[j-1].End >= kr.Begin) {
+				t.Fatalf("%q: ranges are not disjoint or not sorted", ranges)
+			}
+		}
+
+		for j := 0; j < 50; j++ {
+			k := randomKey(r)
+			want := false
+			for _, kr := range added {
+				want = want || kr.Contains(k)
+			}
+			if got := set.Contains(k); got != want {
+				t.Fatalf("%q: key %q: want %t, got %t", ranges, k, want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kv
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// KeyRanger is an optional interface implemented by snapshots and
+// transactions that can iterate over the keys without reading their values.
+// Iterators returned by these methods return nil values from Fetch.
+type KeyRanger interface {
+	// AscendKeys is same as Ranger.Ascend, but returns only the keys.
+	AscendKeys(ctx context.Context, begin, end string) (Iterator, error)
+
+	// DescendKeys is same as Ranger.Descend, but returns only the keys.
+	DescendKeys(ctx context.Context, begin, end string) (Iterator, error)
+
+	// ScanKeys is same as Scanner.Scan, but returns only the keys.
+	ScanKeys(ctx context.Context) (Iterator, error)
+}
+
+// Counter is an optional interface implemented by snapshots and transactions
+// that can count the keys in a range.
+type Counter interface {
+	// Count returns the number of keys in a range. Range is determined by the
+	// `begin` and `end` parameters with the same conventions as the
+	// Ranger.Ascend method.
+	Count(ctx context.Context, begin, end string) (int64, error)
+}
+
+// AscendKeys returns an iterator over the keys of a range in ascending order.
+// It uses the KeyRanger interface when the input implements it and drops the
+// values from a regular iterator otherwise.
+func AscendKeys(ctx context.Context, r Ranger, begin, end string) (Iterator, error) {
+	if v, ok := r.(KeyRanger); ok {
+		return v.AscendKeys(ctx, begin, end)
+	}
+	it, err := r.Ascend(ctx, begin, end)
+	if err != nil {
+		return nil, err
+	}
+	return &keysIterator{it}, nil
+}
+
+// DescendKeys returns an iterator over the keys of a range in descending
+// order. It uses the KeyRanger interface when the input implements it and
+// drops the values from a regular iterator otherwise.
+func DescendKeys(ctx context.Context, r Ranger, begin, end string) (Iterator, error) {
+	if v, ok := r.(KeyRanger); ok {
+		return v.DescendKeys(ctx, begin, end)
+	}
+	it, err := r.Descend(ctx, begin, end)
+	if err != nil {
+		return nil, err
+	}
+	return &keysIterator{it}, nil
+}
+
+// ScanKeys returns an iterator over all keys in no particular order. It uses
+// the KeyRanger interface when the input implements it and drops the values
+// from a regular iterator otherwise.
+func ScanKeys(ctx context.Context, s Scanner) (Iterator, error) {
+	if v, ok := s.(KeyRanger); ok {
+		return v.ScanKeys(ctx)
+	}
+	it, err := s.Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &keysIterator{it}, nil
+}
+
+// Count returns the number of keys in a range. It uses the Counter interface
+// when the input implements it and counts the keys with AscendKeys otherwise.
+func Count(ctx context.Context, r Ranger, begin, end string) (int64, error) {
+	if v, ok := r.(Counter); ok {
+		return v.Count(ctx, begin, end)
+	}
+	it, err := AscendKeys(ctx, r, begin, end)
+	if err != nil {
+		return 0, err
+	}
+	defer Close(it)
+
+	var n int64
+	for _, _, err := it.Fetch(ctx, false); err == nil; _, _, err = it.Fetch(ctx, true) {
+		n++
+	}
+	if _, _, err := it.Fetch(ctx, false); err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+	return n, nil
+}
+
+// keysIterator wraps a regular iterator to drop the values.
+type keysIterator struct {
+	it Iterator
+}
+
+func (v *keysIterator) Fetch(ctx context.Context, next bool) (string, io.Reader, error) {
+	k, _, err := v.it.Fetch(ctx, next)
+	return k, nil, err
+}
+
+func (v *keysIterator) Seek(ctx context.Context, key string) error {
+	if s, ok := v.it.(Seeker); ok {
+		return s.Seek(ctx, key)
+	}
+	return errors.ErrUnsupported
+}
+
+func (v *keysIterator) Close() error {
+	return Close(v.it)
+}
@@ -5,6 +5,7 @@ package kv
 import (
 	"context"
 	"io"
+	"time"
 )
 
 type Getter interface {
@@ -21,6 +22,26 @@ type Setter interface {
 	Set(ctx context.Context, key string, value io.Reader) error
 }
 
+// TTLSetter is an optional interface implemented by transactions that support
+// keys with an expiry time.
+type TTLSetter interface {
+	// SetWithTTL is same as Set, but the key-value pair expires after the
+	// given duration. Expired keys are not visible to the snapshots and
+	// transactions created after the expiry. Setting the key again with Set
+	// removes the expiry time.
+	SetWithTTL(ctx context.Context, key string, value io.Reader, ttl time.Duration) error
+}
+
+// Merger is an optional interface implemented by transactions that support
+// merge operators. Merges combine an operand with the current value of a key
+// without reading it, so that concurrent commutative updates, like counter
+// increments, do not conflict with each other.
+type Merger interface {
+	// Merge records an update that is combined with the value of the key by
+	// the merge operator configured for the key in the database.
+	Merge(ctx context.Context, key string, operand io.Reader) error
+}
+
 type Deleter interface {
 	// Delete removes a key-value pair. Returns nil on success.
 	//
@@ -28,6 +49,16 @@ type Deleter interface {
 	Delete(ctx context.Context, key string) error
 }
 
+// RangeDeleter is an optional interface implemented by transactions that can
+// delete all keys in a range without visiting them individually.
+type RangeDeleter interface {
+	// DeleteRange removes all key-value pairs in a range. Range is determined
+	// by the `begin` and `end` parameters with the same conventions as the
+	// Ranger.Ascend method. Keys set after the DeleteRange in the same
+	// transaction are not deleted. Returns nil on success.
+	DeleteRange(ctx context.Context, begin, end string) error
+}
+
 // Iterator represents a position in a range of key-value pairs visited by
 // Ascend, Descend and Scan operations. If there is any error in reading a
 // key-value pair, it is retained in the iterator and the iteration is stopped.
@@ -49,6 +80,9 @@ type Deleter interface {
 //	if _, _, err := it.Fetch(ctx, false); err != nil && !errors.Is(err, io.EOF) {
 //	  return err
 //	}
+//
+// The All and Range functions wrap the same loop as a range-over-func
+// sequence.
 type Iterator interface {
 	// Fetch returns the key-value pair at the current iterator position or the
 	// next position. If next parameter is true, iterator position is
@@ -60,6 +94,18 @@ type Iterator interface {
 	Fetch(ctx context.Context, next bool) (string, io.Reader, error)
 }
 
+// Seeker is an optional interface implemented by iterators that can be
+// repositioned without creating a new iterator.
+type Seeker interface {
+	// Seek moves an ascending iterator to the smallest key that is greater
+	// than or equal to the input key and a descending iterator to the largest
+	// key that is lesser than or equal to the input key. Iterator stays within
+	// its range, so keys outside the range are clamped to the range. Next
+	// Fetch with next parameter set to false returns the key-value pair at the
+	// new position or io.EOF if there is no such key.
+	Seek(ctx context.Context, key string) error
+}
+
 type Ranger interface {
 	// Ascend returns key-value pairs of a range in ascending order through an
 	// iterator. Range is determined by the `begin` and `end` parameters.
@@ -2,20 +2,37 @@
 
 package api
 
+import "time"
+
 type NewTransactionRequest struct {
 	Name string
+
+	// ReadOnly and Isolation fields correspond to the kv.TransactionOptions.
+	ReadOnly  bool
+	Isolation int
 }
 
 type NewTransactionResponse struct {
 	Error string
+
+	// Version holds the commit version of the data visible to the transaction
+	// when it is supported by the backend.
+	Version int64
 }
 
 type NewSnapshotRequest struct {
 	Name string
+
+	// Version when non-nil opens the snapshot at an older commit version.
+	Version *int64
 }
 
 type NewSnapshotResponse struct {
 	Error string
+
+	// Version holds the commit version of the snapshot when it is supported by
+	// the backend.
+	Version int64
 }
 
 type GetRequest struct {
@@ -31,18 +48,48 @@ type GetResponse struct {
 	Value []byte
 }
 
+type MultiGetRequest struct {
+	Transaction string
+	Snapshot    string
+
+	Keys []string
+}
+
+type MultiGetResponse struct {
+	Error string
+
+	// Values and Errors hold one entry per requested key. Errors entry is
+	// empty when the key is read successfully.
+	Values [][]byte
+	Errors []string
+}
+
 type SetRequest struct {
 	Transaction string
 
 	Key string
 
 	Value []byte
+
+	// TTL when positive sets the key with an expiry time using the
+	// kv.TTLSetter interface.
+	TTL time.Duration
 }
 
 type SetResponse struct {
 	Error string
 }
 
+type MultiSetRequest struct {
+	Transaction string
+
+	Values map[string][]byte
+}
+
+type MultiSetResponse struct {
+	Error string
+}
+
 type DeleteRequest struct {
 	Transaction string
 
@@ -53,6 +100,17 @@ type DeleteResponse struct {
 	Error string
 }
 
+type DeleteRangeRequest struct {
+	Transaction string
+
+	Begin string
+	End   string
+}
+
+type DeleteRangeResponse struct {
+	Error string
+}
+
 type AscendRequest struct {
 	Transaction string
 	Snapshot    string
@@ -62,6 +120,9 @@ type AscendRequest struct {
 	End string
 
 	Name string
+
+	// KeysOnly when true creates an iterator that returns only the keys.
+	KeysOnly bool
 }
 
 type AscendResponse struct {
@@ -77,6 +138,9 @@ type DescendRequest struct {
 	End string
 
 	Name string
+
+	// KeysOnly when true creates an iterator that returns only the keys.
+	KeysOnly bool
 }
 
 type DescendResponse struct {
@@ -88,6 +152,9 @@ type ScanRequest struct {
 	Snapshot    string
 
 	Name string
+
+	// KeysOnly when true creates an iterator that returns only the keys.
+	KeysOnly bool
 }
 
 type ScanResponse struct {
@@ -108,12 +175,59 @@ type FetchResponse struct {
 	Value []byte
 }
 
+type SeekRequest struct {
+	Iterator string
+
+	Key string
+}
+
+// SeekResponse holds the key-value pair at the new iterator position, so that
+// clients do not need another round trip to fetch it.
+type SeekResponse struct {
+	Error string
+
+	Key string
+
+	Value []byte
+}
+
+type CountRequest struct {
+	Transaction string
+	Snapshot    string
+
+	Begin string
+	End   string
+}
+
+type CountResponse struct {
+	Error string
+
+	Count int64
+}
+
 type CommitRequest struct {
 	Transaction string
 }
 
 type CommitResponse struct {
 	Error string
+
+	// Conflict holds the conflict details when commit fails because of
+	// conflicting transactions.
+	Conflict *Conflict
+
+	// Version holds the commit version assigned to the transaction when it is
+	// supported by the backend. It is zero otherwise.
+	Version int64
+}
+
+type Conflict struct {
+	Key    string
+	Reason string
+
+	ReadVersion    int64
+	CurrentVersion int64
+	TxVersion      int64
 }
 
 type RollbackRequest struct {
@@ -131,3 +245,47 @@ type DiscardRequest struct {
 type DiscardResponse struct {
 	Error string
 }
+
+type HistoryRequest struct {
+	Snapshot string
+
+	Key string
+}
+
+type HistoryResponse struct {
+	Error string
+
+	Changes []*Change
+}
+
+type Change struct {
+	Key     string
+	Value   []byte
+	Deleted bool
+	Version int64
+}
+
+// ConditionalWriteRequest holds a single key update that is applied only if
+// the condition holds. Op must be one of "compare-and-set",
+// "compare-version-and-set", "set-if-absent" or "delete-if-equal".
+type ConditionalWriteRequest struct {
+	Op string
+
+	Key string
+
+	// OldValue is the expected current value for the compare-and-set and
+	// delete-if-equal operations.
+	OldValue []byte
+
+	// OldVersion is the expected commit version of the current value for the
+	// compare-version-and-set operation.
+	OldVersion int64
+
+	// Value is the new value for the compare-and-set, compare-version-and-set
+	// and set-if-absent operations.
+	Value []byte
+}
+
+type ConditionalWriteResponse struct {
+	Error string
+}
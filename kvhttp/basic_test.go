@@ -4,10 +4,16 @@ package kvhttp
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/bvkgo/kv"
 	"github.com/bvkgo/kv/kvmemdb"
 	"github.com/bvkgo/kv/kvtests"
 )
@@ -39,3 +45,451 @@ func TestTxSemantics(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestConflictError(t *testing.T) {
+	ctx := context.Background()
+
+	s := httptest.NewServer(Handler(kvmemdb.New()))
+	defer s.Close()
+
+	addrURL, _ := url.Parse(s.URL)
+	db := New(addrURL, s.Client())
+
+	if err := kv.WithReadWriter(ctx, db, func(ctx context.Context, rw kv.ReadWriter) error {
+		return rw.Set(ctx, "key", strings.NewReader("value"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tx1, err := db.NewTransaction(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx2, err := db.NewTransaction(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tx1.Get(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Delete(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	err = tx1.Commit(ctx)
+	if !errors.Is(err, kv.ErrConflict) {
+		t.Fatalf("want ErrConflict, got %v", err)
+	}
+	cerr := new(kv.ConflictError)
+	if !errors.As(err, &cerr) {
+		t.Fatalf("want a ConflictError, got %v", err)
+	}
+	if cerr.Key != "key" || cerr.Reason != kv.ConflictDeleted {
+		t.Fatalf("unexpected conflict error details %#v", cerr)
+	}
+}
+
+func TestVersions(t *testing.T) {
+	ctx := context.Background()
+
+	s := httptest.NewServer(Handler(kvmemdb.NewWithOptions(&kvmemdb.Options{RetainVersions: 10})))
+	defer s.Close()
+
+	addrURL, _ := url.Parse(s.URL)
+	db := New(addrURL, s.Client())
+
+	var versions []int64
+	for _, value := range []string{"one", "two", "three"} {
+		tx, err := db.NewTransaction(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Set(ctx, "key", strings.NewReader(value)); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+		version, ok := tx.(kv.CommitVersioner).CommitVersion()
+		if !ok {
+			t.Fatalf("commit version is not available")
+		}
+		if n := len(versions); n > 0 && version <= versions[n-1] {
+			t.Fatalf("commit version %d is not larger than previous version %d", version, versions[n-1])
+		}
+		versions = append(versions, version)
+	}
+
+	snap, err := db.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Discard(ctx)
+
+	if v := snap.(kv.CommitVersioner).ReadVersion(); v != versions[2] {
+		t.Fatalf("want snapshot version %d, got %d", versions[2], v)
+	}
+
+	old, err := db.NewSnapshotAt(ctx, versions[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer old.Discard(ctx)
+
+	v, err := old.Get(ctx, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(v); string(data) != "one" {
+		t.Fatalf("want value one, got %s", data)
+	}
+}
+
+func TestReadOnlyTransaction(t *testing.T) {
+	ctx := context.Background()
+
+	s := httptest.NewServer(Handler(kvmemdb.New()))
+	defer s.Close()
+
+	addrURL, _ := url.Parse(s.URL)
+	db := New(addrURL, s.Client())
+
+	if err := kv.WithReadWriter(ctx, db, func(ctx context.Context, rw kv.ReadWriter) error {
+		return rw.Set(ctx, "key", strings.NewReader("value"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ro, err := kv.NewTransaction(ctx, db, &kv.TransactionOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ro.Get(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ro.Set(ctx, "key", strings.NewReader("VALUE")); !errors.Is(err, os.ErrPermission) {
+		t.Fatalf("want ErrPermission, got %v", err)
+	}
+
+	if err := kv.WithReadWriter(ctx, db, func(ctx context.Context, rw kv.ReadWriter) error {
+		return rw.Set(ctx, "key", strings.NewReader("VALUE"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Read-only transactions never fail at commit.
+	if err := ro.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	ctx := context.Background()
+
+	s := httptest.NewServer(Handler(kvmemdb.NewWithOptions(&kvmemdb.Options{RetainVersions: 10})))
+	defer s.Close()
+
+	addrURL, _ := url.Parse(s.URL)
+	db := New(addrURL, s.Client())
+
+	for _, value := range []string{"one", "", "two"} {
+		update := func(ctx context.Context, rw kv.ReadWriter) error {
+			if value == "" {
+				return rw.Delete(ctx, "key")
+			}
+			return rw.Set(ctx, "key", strings.NewReader(value))
+		}
+		if err := kv.WithReadWriter(ctx, db, update); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snap, err := db.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Discard(ctx)
+
+	it, err := snap.(kv.Historian).History(ctx, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	want := []kv.Change{
+		{Key: "key", Value: []byte("one"), Version: 1},
+		{Key: "key", Deleted: true, Version: 2},
+		{Key: "key", Value: []byte("two"), Version: 3},
+	}
+	for _, w := range want {
+		c, err := it.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Key != w.Key || string(c.Value) != string(w.Value) || c.Deleted != w.Deleted || c.Version != w.Version {
+			t.Fatalf("want %+v, got %+v", w, *c)
+		}
+	}
+	if _, err := it.Next(ctx); !errors.Is(err, io.EOF) {
+		t.Fatalf("want EOF, got %v", err)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	ctx := context.Background()
+
+	s := httptest.NewServer(Handler(kvmemdb.New()))
+	defer s.Close()
+
+	addrURL, _ := url.Parse(s.URL)
+	db := New(addrURL, s.Client())
+
+	set := func(ctx context.Context, rw kv.ReadWriter) error {
+		return rw.(kv.TTLSetter).SetWithTTL(ctx, "key", strings.NewReader("value"), 10*time.Millisecond)
+	}
+	if err := kv.WithReadWriter(ctx, db, set); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	snap, err := db.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Discard(ctx)
+
+	if _, err := snap.Get(ctx, "key"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("want ErrNotExist for the expired key, got %v", err)
+	}
+}
+
+func TestConditionalWrites(t *testing.T) {
+	ctx := context.Background()
+
+	// Server falls back to transactions for databases that do not implement
+	// the kv.ConditionalWriter interface.
+	for _, sdb := range []kv.Database{kvmemdb.New(), struct{ kv.Database }{kvmemdb.New()}} {
+		s := httptest.NewServer(Handler(sdb))
+		defer s.Close()
+
+		addrURL, _ := url.Parse(s.URL)
+		db := New(addrURL, s.Client())
+
+		if err := db.SetIfAbsent(ctx, "key", strings.NewReader("one")); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.SetIfAbsent(ctx, "key", strings.NewReader("two")); !errors.Is(err, kv.ErrConditionFailed) {
+			t.Fatalf("want ErrConditionFailed, got %v", err)
+		}
+		if err := db.CompareAndSet(ctx, "key", strings.NewReader("two"), strings.NewReader("three")); !errors.Is(err, kv.ErrConditionFailed) {
+			t.Fatalf("want ErrConditionFailed, got %v", err)
+		}
+		if err := db.CompareAndSet(ctx, "key", strings.NewReader("one"), strings.NewReader("two")); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.DeleteIfEqual(ctx, "key", strings.NewReader("one")); !errors.Is(err, kv.ErrConditionFailed) {
+			t.Fatalf("want ErrConditionFailed, got %v", err)
+		}
+		if err := db.DeleteIfEqual(ctx, "key", strings.NewReader("two")); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.DeleteIfEqual(ctx, "key", strings.NewReader("two")); !errors.Is(err, kv.ErrConditionFailed) {
+			t.Fatalf("want ErrConditionFailed for a missing key, got %v", err)
+		}
+
+		// Version based updates are not supported by the fallback.
+		err := db.CompareVersionAndSet(ctx, "key", 0, strings.NewReader("one"))
+		if _, ok := sdb.(kv.ConditionalWriter); !ok {
+			if !errors.Is(err, errors.ErrUnsupported) {
+				t.Fatalf("want ErrUnsupported, got %v", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.CompareVersionAndSet(ctx, "key", 0, strings.NewReader("two")); !errors.Is(err, kv.ErrConditionFailed) {
+			t.Fatalf("want ErrConditionFailed, got %v", err)
+		}
+	}
+}
+
+func TestDeleteRange(t *testing.T) {
+	ctx := context.Background()
+
+	s := httptest.NewServer(Handler(kvmemdb.New()))
+	defer s.Close()
+
+	addrURL, _ := url.Parse(s.URL)
+	db := New(addrURL, s.Client())
+
+	update := func(ctx context.Context, rw kv.ReadWriter) error {
+		for _, k := range []string{"a", "b", "c", "d"} {
+			if err := rw.Set(ctx, k, strings.NewReader(k)); err != nil {
+				return err
+			}
+		}
+		return rw.(kv.RangeDeleter).DeleteRange(ctx, "b", "d")
+	}
+	if err := kv.WithReadWriter(ctx, db, update); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := db.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Discard(ctx)
+
+	for k, exists := range map[string]bool{"a": true, "b": false, "c": false, "d": true} {
+		if _, err := snap.Get(ctx, k); (err == nil) != exists {
+			t.Fatalf("key %s: want exists=%t, got %v", k, exists, err)
+		}
+	}
+}
+
+func TestMultiGetSet(t *testing.T) {
+	ctx := context.Background()
+
+	s := httptest.NewServer(Handler(kvmemdb.New()))
+	defer s.Close()
+
+	addrURL, _ := url.Parse(s.URL)
+	db := New(addrURL, s.Client())
+
+	set := func(ctx context.Context, rw kv.ReadWriter) error {
+		kvs := map[string]io.Reader{
+			"a": strings.NewReader("1"),
+			"b": strings.NewReader(""),
+		}
+		return kv.SetMany(ctx, rw, kvs)
+	}
+	if err := kv.WithReadWriter(ctx, db, set); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := db.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Discard(ctx)
+
+	values, errs, err := snap.(kv.BatchGetter).MultiGet(ctx, []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs[0] != nil || errs[1] != nil || !errors.Is(errs[2], os.ErrNotExist) {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+	if data, _ := io.ReadAll(values[0]); string(data) != "1" {
+		t.Fatalf("want 1, got %s", data)
+	}
+	if data, _ := io.ReadAll(values[1]); len(data) != 0 {
+		t.Fatalf("want empty value, got %s", data)
+	}
+}
+
+func TestSeek(t *testing.T) {
+	ctx := context.Background()
+
+	s := httptest.NewServer(Handler(kvmemdb.New()))
+	defer s.Close()
+
+	addrURL, _ := url.Parse(s.URL)
+	db := New(addrURL, s.Client())
+
+	set := func(ctx context.Context, rw kv.ReadWriter) error {
+		for _, k := range []string{"a", "b", "c", "d"} {
+			if err := rw.Set(ctx, k, strings.NewReader(k)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := kv.WithReadWriter(ctx, db, set); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := db.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Discard(ctx)
+
+	it, err := snap.Ascend(ctx, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seeker := it.(kv.Seeker)
+	if err := seeker.Seek(ctx, "z"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := it.Fetch(ctx, false); !errors.Is(err, io.EOF) {
+		t.Fatalf("want EOF, got %v", err)
+	}
+
+	// Iterator can be repositioned after reaching the end.
+	if err := seeker.Seek(ctx, "bb"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"c", "d"} {
+		k, v, err := it.Fetch(ctx, want == "d")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := io.ReadAll(v); k != want || string(data) != want {
+			t.Fatalf("want %s, got %s=%s", want, k, data)
+		}
+	}
+}
+
+func TestKeysOnly(t *testing.T) {
+	ctx := context.Background()
+
+	s := httptest.NewServer(Handler(kvmemdb.New()))
+	defer s.Close()
+
+	addrURL, _ := url.Parse(s.URL)
+	db := New(addrURL, s.Client())
+
+	set := func(ctx context.Context, rw kv.ReadWriter) error {
+		for _, k := range []string{"a", "b", "c"} {
+			if err := rw.Set(ctx, k, strings.NewReader(k)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := kv.WithReadWriter(ctx, db, set); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := db.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Discard(ctx)
+
+	it, err := kv.DescendKeys(ctx, snap, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for k, v, err := it.Fetch(ctx, false); err == nil; k, v, err = it.Fetch(ctx, true) {
+		if v != nil {
+			t.Fatalf("want nil value for key %s", k)
+		}
+		keys = append(keys, k)
+	}
+	if s := strings.Join(keys, ","); s != "c,b,a" {
+		t.Fatalf("want c,b,a, got %s", s)
+	}
+
+	if n, err := kv.Count(ctx, snap, "b", ""); err != nil || n != 2 {
+		t.Fatalf("want 2 keys, got %d, %v", n, err)
+	}
+}
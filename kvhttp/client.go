@@ -6,11 +6,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"path"
+	"time"
 
 	"github.com/bvkgo/kv"
 	"github.com/bvkgo/kv/kvhttp/api"
@@ -28,17 +31,25 @@ type DB struct {
 type Tx struct {
 	db *DB
 	id string
+
+	readVersion   int64
+	commitVersion int64
 }
 
 type Snap struct {
 	db *DB
 	id string
+
+	version int64
 }
 
 type Iter struct {
 	db *DB
 	id string
 
+	// keysOnly is true for the iterators that do not receive the values.
+	keysOnly bool
+
 	cache struct {
 		err   error
 		key   string
@@ -71,15 +82,27 @@ func (db *DB) ServerURL() url.URL {
 }
 
 func (db *DB) NewTransaction(ctx context.Context) (kv.Transaction, error) {
+	return db.NewTransactionWithOptions(ctx, nil)
+}
+
+// NewTransactionWithOptions creates a transaction with the input options on
+// the server. Returns errors.ErrUnsupported if the server side database
+// doesn't support the options.
+func (db *DB) NewTransactionWithOptions(ctx context.Context, opts *kv.TransactionOptions) (kv.Transaction, error) {
 	id := uuid.New().String()
-	resp, err := doPost[api.NewTransactionResponse](ctx, db, "/new-transaction", &api.NewTransactionRequest{Name: id})
+	req := &api.NewTransactionRequest{Name: id}
+	if opts != nil {
+		req.ReadOnly = opts.ReadOnly
+		req.Isolation = int(opts.Isolation)
+	}
+	resp, err := doPost[api.NewTransactionResponse](ctx, db, "/new-transaction", req)
 	if err != nil {
 		return nil, err
 	}
 	if len(resp.Error) != 0 {
 		return nil, string2error(resp.Error)
 	}
-	return &Tx{db: db, id: id}, nil
+	return &Tx{db: db, id: id, readVersion: resp.Version}, nil
 }
 
 func (db *DB) NewSnapshot(ctx context.Context) (kv.Snapshot, error) {
@@ -91,7 +114,95 @@ func (db *DB) NewSnapshot(ctx context.Context) (kv.Snapshot, error) {
 	if len(resp.Error) != 0 {
 		return nil, string2error(resp.Error)
 	}
-	return &Snap{db: db, id: id}, nil
+	return &Snap{db: db, id: id, version: resp.Version}, nil
+}
+
+// NewSnapshotAt returns a snapshot at an older commit version. Returns
+// errors.ErrUnsupported if the server side database doesn't implement the
+// kv.VersionedDatabase interface.
+func (db *DB) NewSnapshotAt(ctx context.Context, version int64) (kv.Snapshot, error) {
+	id := uuid.New().String()
+	req := &api.NewSnapshotRequest{Name: id, Version: &version}
+	resp, err := doPost[api.NewSnapshotResponse](ctx, db, "/new-snapshot", req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Error) != 0 {
+		return nil, string2error(resp.Error)
+	}
+	return &Snap{db: db, id: id, version: resp.Version}, nil
+}
+
+// CompareAndSet updates a key to newValue if its current value is equal to
+// oldValue in a single round trip. Server uses a transaction when the server
+// side database doesn't implement the kv.ConditionalWriter interface.
+func (db *DB) CompareAndSet(ctx context.Context, key string, oldValue, newValue io.Reader) error {
+	old, err := io.ReadAll(oldValue)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(newValue)
+	if err != nil {
+		return err
+	}
+	return db.conditionalWrite(ctx, &api.ConditionalWriteRequest{Op: "compare-and-set", Key: key, OldValue: old, Value: data})
+}
+
+// CompareVersionAndSet updates a key to newValue if the commit version of its
+// current value is equal to the given version in a single round trip. Returns
+// errors.ErrUnsupported if the server side database doesn't implement the
+// kv.ConditionalWriter interface.
+func (db *DB) CompareVersionAndSet(ctx context.Context, key string, version int64, newValue io.Reader) error {
+	data, err := io.ReadAll(newValue)
+	if err != nil {
+		return err
+	}
+	return db.conditionalWrite(ctx, &api.ConditionalWriteRequest{Op: "compare-version-and-set", Key: key, OldVersion: version, Value: data})
+}
+
+// SetIfAbsent creates a key with the given value if it doesn't exist in a
+// single round trip.
+func (db *DB) SetIfAbsent(ctx context.Context, key string, value io.Reader) error {
+	data, err := io.ReadAll(value)
+	if err != nil {
+		return err
+	}
+	return db.conditionalWrite(ctx, &api.ConditionalWriteRequest{Op: "set-if-absent", Key: key, Value: data})
+}
+
+// DeleteIfEqual deletes a key if its current value is equal to the given value
+// in a single round trip.
+func (db *DB) DeleteIfEqual(ctx context.Context, key string, value io.Reader) error {
+	old, err := io.ReadAll(value)
+	if err != nil {
+		return err
+	}
+	return db.conditionalWrite(ctx, &api.ConditionalWriteRequest{Op: "delete-if-equal", Key: key, OldValue: old})
+}
+
+func (db *DB) conditionalWrite(ctx context.Context, req *api.ConditionalWriteRequest) error {
+	resp, err := doPost[api.ConditionalWriteResponse](ctx, db, "/conditional-write", req)
+	if err != nil {
+		return err
+	}
+	if len(resp.Error) != 0 {
+		return string2error(resp.Error)
+	}
+	return nil
+}
+
+// ReadVersion returns the commit version of the data visible to the
+// transaction as reported by the server. It is zero if the server side
+// database doesn't support versions.
+func (tx *Tx) ReadVersion() int64 {
+	return tx.readVersion
+}
+
+// CommitVersion returns the commit version assigned to the transaction by the
+// server. Returns false if the transaction is not committed or if the server
+// side database doesn't support versions.
+func (tx *Tx) CommitVersion() (int64, bool) {
+	return tx.commitVersion, tx.commitVersion != 0
 }
 
 func (tx *Tx) Get(ctx context.Context, key string) (io.Reader, error) {
@@ -106,7 +217,47 @@ func (tx *Tx) Get(ctx context.Context, key string) (io.Reader, error) {
 	return bytes.NewReader(resp.Value), nil
 }
 
+// MultiGet reads multiple keys with a single request.
+func (tx *Tx) MultiGet(ctx context.Context, keys []string) ([]io.Reader, []error, error) {
+	req := &api.MultiGetRequest{Transaction: tx.id, Keys: keys}
+	return multiGet(ctx, tx.db, "/tx/multi-get", req)
+}
+
+// MultiSet creates or updates multiple keys with a single request.
+func (tx *Tx) MultiSet(ctx context.Context, kvs map[string]io.Reader) error {
+	values := make(map[string][]byte, len(kvs))
+	for k, v := range kvs {
+		data, err := io.ReadAll(v)
+		if err != nil {
+			return err
+		}
+		values[k] = data
+	}
+	req := &api.MultiSetRequest{Transaction: tx.id, Values: values}
+	resp, err := doPost[api.MultiSetResponse](ctx, tx.db, "/tx/multi-set", req)
+	if err != nil {
+		return err
+	}
+	if len(resp.Error) != 0 {
+		return string2error(resp.Error)
+	}
+	return nil
+}
+
 func (tx *Tx) Set(ctx context.Context, key string, value io.Reader) error {
+	return tx.set(ctx, key, value, 0)
+}
+
+// SetWithTTL sets a key with an expiry time. Returns errors.ErrUnsupported if
+// the server side transactions do not implement the kv.TTLSetter interface.
+func (tx *Tx) SetWithTTL(ctx context.Context, key string, value io.Reader, ttl time.Duration) error {
+	if ttl <= 0 {
+		return os.ErrInvalid
+	}
+	return tx.set(ctx, key, value, ttl)
+}
+
+func (tx *Tx) set(ctx context.Context, key string, value io.Reader, ttl time.Duration) error {
 	data, err := io.ReadAll(value)
 	if err != nil {
 		return err
@@ -115,6 +266,7 @@ func (tx *Tx) Set(ctx context.Context, key string, value io.Reader) error {
 		Transaction: tx.id,
 		Key:         key,
 		Value:       data,
+		TTL:         ttl,
 	}
 	resp, err := doPost[api.SetResponse](ctx, tx.db, "/tx/set", req)
 	if err != nil {
@@ -138,12 +290,37 @@ func (tx *Tx) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
+// DeleteRange deletes all keys in a range with a single request. Server
+// deletes the keys one by one if the server side transactions do not
+// implement the kv.RangeDeleter interface.
+func (tx *Tx) DeleteRange(ctx context.Context, begin, end string) error {
+	req := &api.DeleteRangeRequest{Transaction: tx.id, Begin: begin, End: end}
+	resp, err := doPost[api.DeleteRangeResponse](ctx, tx.db, "/tx/delete-range", req)
+	if err != nil {
+		return err
+	}
+	if len(resp.Error) != 0 {
+		return string2error(resp.Error)
+	}
+	return nil
+}
+
 func (tx *Tx) Ascend(ctx context.Context, begin, end string) (kv.Iterator, error) {
+	return tx.ascend(ctx, begin, end, false)
+}
+
+// AscendKeys is same as Ascend, but the server doesn't send the values.
+func (tx *Tx) AscendKeys(ctx context.Context, begin, end string) (kv.Iterator, error) {
+	return tx.ascend(ctx, begin, end, true)
+}
+
+func (tx *Tx) ascend(ctx context.Context, begin, end string, keysOnly bool) (kv.Iterator, error) {
 	req := &api.AscendRequest{
 		Transaction: tx.id,
 		Name:        uuid.New().String(),
 		Begin:       begin,
 		End:         end,
+		KeysOnly:    keysOnly,
 	}
 	resp, err := doPost[api.AscendResponse](ctx, tx.db, "/tx/ascend", req)
 	if err != nil {
@@ -152,16 +329,26 @@ func (tx *Tx) Ascend(ctx context.Context, begin, end string) (kv.Iterator, error
 	if len(resp.Error) != 0 {
 		return nil, string2error(resp.Error)
 	}
-	it := &Iter{db: tx.db, id: req.Name}
+	it := &Iter{db: tx.db, id: req.Name, keysOnly: keysOnly}
 	return it, nil
 }
 
 func (tx *Tx) Descend(ctx context.Context, begin, end string) (kv.Iterator, error) {
+	return tx.descend(ctx, begin, end, false)
+}
+
+// DescendKeys is same as Descend, but the server doesn't send the values.
+func (tx *Tx) DescendKeys(ctx context.Context, begin, end string) (kv.Iterator, error) {
+	return tx.descend(ctx, begin, end, true)
+}
+
+func (tx *Tx) descend(ctx context.Context, begin, end string, keysOnly bool) (kv.Iterator, error) {
 	req := &api.DescendRequest{
 		Transaction: tx.id,
 		Name:        uuid.New().String(),
 		Begin:       begin,
 		End:         end,
+		KeysOnly:    keysOnly,
 	}
 	resp, err := doPost[api.DescendResponse](ctx, tx.db, "/tx/descend", req)
 	if err != nil {
@@ -170,12 +357,21 @@ func (tx *Tx) Descend(ctx context.Context, begin, end string) (kv.Iterator, erro
 	if len(resp.Error) != 0 {
 		return nil, string2error(resp.Error)
 	}
-	it := &Iter{db: tx.db, id: req.Name}
+	it := &Iter{db: tx.db, id: req.Name, keysOnly: keysOnly}
 	return it, nil
 }
 
 func (tx *Tx) Scan(ctx context.Context) (kv.Iterator, error) {
-	req := &api.ScanRequest{Transaction: tx.id, Name: uuid.New().String()}
+	return tx.scan(ctx, false)
+}
+
+// ScanKeys is same as Scan, but the server doesn't send the values.
+func (tx *Tx) ScanKeys(ctx context.Context) (kv.Iterator, error) {
+	return tx.scan(ctx, true)
+}
+
+func (tx *Tx) scan(ctx context.Context, keysOnly bool) (kv.Iterator, error) {
+	req := &api.ScanRequest{Transaction: tx.id, Name: uuid.New().String(), KeysOnly: keysOnly}
 	resp, err := doPost[api.ScanResponse](ctx, tx.db, "/tx/scan", req)
 	if err != nil {
 		return nil, err
@@ -183,19 +379,29 @@ func (tx *Tx) Scan(ctx context.Context) (kv.Iterator, error) {
 	if len(resp.Error) != 0 {
 		return nil, string2error(resp.Error)
 	}
-	it := &Iter{db: tx.db, id: req.Name}
+	it := &Iter{db: tx.db, id: req.Name, keysOnly: keysOnly}
 	return it, nil
 }
 
+// Count returns the number of keys in a range with a single request.
+func (tx *Tx) Count(ctx context.Context, begin, end string) (int64, error) {
+	req := &api.CountRequest{Transaction: tx.id, Begin: begin, End: end}
+	return count(ctx, tx.db, "/tx/count", req)
+}
+
 func (tx *Tx) Commit(ctx context.Context) error {
 	req := &api.CommitRequest{Transaction: tx.id}
 	resp, err := doPost[api.CommitResponse](ctx, tx.db, "/tx/commit", req)
 	if err != nil {
 		return err
 	}
+	if resp.Conflict != nil {
+		return conflict2error(resp.Conflict)
+	}
 	if len(resp.Error) != 0 {
 		return string2error(resp.Error)
 	}
+	tx.commitVersion = resp.Version
 	return nil
 }
 
@@ -211,6 +417,22 @@ func (tx *Tx) Rollback(ctx context.Context) error {
 	return nil
 }
 
+// Version returns the commit version of the snapshot as reported by the
+// server. It is zero if the server side database doesn't support versions.
+func (snap *Snap) Version() int64 {
+	return snap.version
+}
+
+// ReadVersion is same as Version.
+func (snap *Snap) ReadVersion() int64 {
+	return snap.version
+}
+
+// CommitVersion always returns false because snapshots are never committed.
+func (snap *Snap) CommitVersion() (int64, bool) {
+	return 0, false
+}
+
 func (snap *Snap) Get(ctx context.Context, key string) (io.Reader, error) {
 	req := &api.GetRequest{Snapshot: snap.id, Key: key}
 	resp, err := doPost[api.GetResponse](ctx, snap.db, "/snap/get", req)
@@ -223,12 +445,51 @@ func (snap *Snap) Get(ctx context.Context, key string) (io.Reader, error) {
 	return bytes.NewReader(resp.Value), nil
 }
 
+// MultiGet reads multiple keys with a single request.
+func (snap *Snap) MultiGet(ctx context.Context, keys []string) ([]io.Reader, []error, error) {
+	req := &api.MultiGetRequest{Snapshot: snap.id, Keys: keys}
+	return multiGet(ctx, snap.db, "/snap/multi-get", req)
+}
+
+func multiGet(ctx context.Context, db *DB, subpath string, req *api.MultiGetRequest) ([]io.Reader, []error, error) {
+	resp, err := doPost[api.MultiGetResponse](ctx, db, subpath, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(resp.Error) != 0 {
+		return nil, nil, string2error(resp.Error)
+	}
+	if len(resp.Values) != len(req.Keys) || len(resp.Errors) != len(req.Keys) {
+		return nil, nil, fmt.Errorf("unexpected number of values in the response")
+	}
+	values := make([]io.Reader, len(req.Keys))
+	errs := make([]error, len(req.Keys))
+	for i := range req.Keys {
+		if len(resp.Errors[i]) != 0 {
+			errs[i] = string2error(resp.Errors[i])
+			continue
+		}
+		values[i] = bytes.NewReader(resp.Values[i])
+	}
+	return values, errs, nil
+}
+
 func (snap *Snap) Ascend(ctx context.Context, begin, end string) (kv.Iterator, error) {
+	return snap.ascend(ctx, begin, end, false)
+}
+
+// AscendKeys is same as Ascend, but the server doesn't send the values.
+func (snap *Snap) AscendKeys(ctx context.Context, begin, end string) (kv.Iterator, error) {
+	return snap.ascend(ctx, begin, end, true)
+}
+
+func (snap *Snap) ascend(ctx context.Context, begin, end string, keysOnly bool) (kv.Iterator, error) {
 	req := &api.AscendRequest{
 		Snapshot: snap.id,
 		Name:     uuid.New().String(),
 		Begin:    begin,
 		End:      end,
+		KeysOnly: keysOnly,
 	}
 	resp, err := doPost[api.AscendResponse](ctx, snap.db, "/snap/ascend", req)
 	if err != nil {
@@ -237,16 +498,26 @@ func (snap *Snap) Ascend(ctx context.Context, begin, end string) (kv.Iterator, e
 	if len(resp.Error) != 0 {
 		return nil, string2error(resp.Error)
 	}
-	it := &Iter{db: snap.db, id: req.Name}
+	it := &Iter{db: snap.db, id: req.Name, keysOnly: keysOnly}
 	return it, nil
 }
 
 func (snap *Snap) Descend(ctx context.Context, begin, end string) (kv.Iterator, error) {
+	return snap.descend(ctx, begin, end, false)
+}
+
+// DescendKeys is same as Descend, but the server doesn't send the values.
+func (snap *Snap) DescendKeys(ctx context.Context, begin, end string) (kv.Iterator, error) {
+	return snap.descend(ctx, begin, end, true)
+}
+
+func (snap *Snap) descend(ctx context.Context, begin, end string, keysOnly bool) (kv.Iterator, error) {
 	req := &api.DescendRequest{
 		Snapshot: snap.id,
 		Name:     uuid.New().String(),
 		Begin:    begin,
 		End:      end,
+		KeysOnly: keysOnly,
 	}
 	resp, err := doPost[api.DescendResponse](ctx, snap.db, "/snap/descend", req)
 	if err != nil {
@@ -255,12 +526,21 @@ func (snap *Snap) Descend(ctx context.Context, begin, end string) (kv.Iterator,
 	if len(resp.Error) != 0 {
 		return nil, string2error(resp.Error)
 	}
-	it := &Iter{db: snap.db, id: req.Name}
+	it := &Iter{db: snap.db, id: req.Name, keysOnly: keysOnly}
 	return it, nil
 }
 
 func (snap *Snap) Scan(ctx context.Context) (kv.Iterator, error) {
-	req := &api.ScanRequest{Snapshot: snap.id, Name: uuid.New().String()}
+	return snap.scan(ctx, false)
+}
+
+// ScanKeys is same as Scan, but the server doesn't send the values.
+func (snap *Snap) ScanKeys(ctx context.Context) (kv.Iterator, error) {
+	return snap.scan(ctx, true)
+}
+
+func (snap *Snap) scan(ctx context.Context, keysOnly bool) (kv.Iterator, error) {
+	req := &api.ScanRequest{Snapshot: snap.id, Name: uuid.New().String(), KeysOnly: keysOnly}
 	resp, err := doPost[api.ScanResponse](ctx, snap.db, "/snap/scan", req)
 	if err != nil {
 		return nil, err
@@ -268,10 +548,27 @@ func (snap *Snap) Scan(ctx context.Context) (kv.Iterator, error) {
 	if len(resp.Error) != 0 {
 		return nil, string2error(resp.Error)
 	}
-	it := &Iter{db: snap.db, id: req.Name}
+	it := &Iter{db: snap.db, id: req.Name, keysOnly: keysOnly}
 	return it, nil
 }
 
+// Count returns the number of keys in a range with a single request.
+func (snap *Snap) Count(ctx context.Context, begin, end string) (int64, error) {
+	req := &api.CountRequest{Snapshot: snap.id, Begin: begin, End: end}
+	return count(ctx, snap.db, "/snap/count", req)
+}
+
+func count(ctx context.Context, db *DB, subpath string, req *api.CountRequest) (int64, error) {
+	resp, err := doPost[api.CountResponse](ctx, db, subpath, req)
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Error) != 0 {
+		return 0, string2error(resp.Error)
+	}
+	return resp.Count, nil
+}
+
 func (snap *Snap) Discard(ctx context.Context) error {
 	req := &api.DiscardRequest{Snapshot: snap.id}
 	resp, err := doPost[api.DiscardResponse](ctx, snap.db, "/snap/discard", req)
@@ -284,6 +581,30 @@ func (snap *Snap) Discard(ctx context.Context) error {
 	return nil
 }
 
+// History returns the versions of a key retained by the server side
+// database. Returns errors.ErrUnsupported if the server side snapshots do not
+// implement the kv.Historian interface.
+func (snap *Snap) History(ctx context.Context, key string) (kv.ChangeIterator, error) {
+	req := &api.HistoryRequest{Snapshot: snap.id, Key: key}
+	resp, err := doPost[api.HistoryResponse](ctx, snap.db, "/snap/history", req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Error) != 0 {
+		return nil, string2error(resp.Error)
+	}
+	changes := make([]*kv.Change, 0, len(resp.Changes))
+	for _, c := range resp.Changes {
+		changes = append(changes, &kv.Change{
+			Key:     c.Key,
+			Value:   c.Value,
+			Deleted: c.Deleted,
+			Version: c.Version,
+		})
+	}
+	return kv.NewChangeList(changes), nil
+}
+
 func (it *Iter) Fetch(ctx context.Context, next bool) (string, io.Reader, error) {
 	if it.cache.err != nil {
 		return "", nil, it.cache.err
@@ -303,10 +624,38 @@ func (it *Iter) Fetch(ctx context.Context, next bool) (string, io.Reader, error)
 		return "", nil, it.cache.err
 	}
 	it.cache.key = resp.Key
-	it.cache.value = bytes.NewReader(resp.Value)
+	if !it.keysOnly {
+		it.cache.value = bytes.NewReader(resp.Value)
+	}
 	return it.cache.key, it.cache.value, nil
 }
 
+// Seek repositions the iterator. Returns errors.ErrUnsupported if the server
+// side iterator doesn't implement the kv.Seeker interface.
+func (it *Iter) Seek(ctx context.Context, key string) error {
+	req := &api.SeekRequest{Iterator: it.id, Key: key}
+	resp, err := doPost[api.SeekResponse](ctx, it.db, "/it/seek", req)
+	if err != nil {
+		return err
+	}
+	if resp.Error == error2string(errors.ErrUnsupported) {
+		return errors.ErrUnsupported
+	}
+
+	// Seek also fetches the key-value pair at the new position, which includes
+	// io.EOF when the new position is past the end.
+	it.cache.err, it.cache.key, it.cache.value = nil, "", nil
+	if len(resp.Error) != 0 {
+		it.cache.err = string2error(resp.Error)
+		return nil
+	}
+	it.cache.key = resp.Key
+	if !it.keysOnly {
+		it.cache.value = bytes.NewReader(resp.Value)
+	}
+	return nil
+}
+
 func doPost[RESP, REQ any](ctx context.Context, db *DB, subpath string, req *REQ) (*RESP, error) {
 	u := url.URL{
 		Host:   db.dbURL.Host,
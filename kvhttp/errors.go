@@ -6,6 +6,9 @@ import (
 	"errors"
 	"io"
 	"os"
+
+	"github.com/bvkgo/kv"
+	"github.com/bvkgo/kv/kvhttp/api"
 )
 
 func error2string(err error) string {
@@ -15,9 +18,21 @@ func error2string(err error) string {
 	if errors.Is(err, os.ErrNotExist) {
 		return "ErrNotExist"
 	}
+	if errors.Is(err, os.ErrPermission) {
+		return "ErrPermission"
+	}
 	if errors.Is(err, io.EOF) {
 		return "EOF"
 	}
+	if errors.Is(err, kv.ErrConflict) {
+		return "ErrConflict"
+	}
+	if errors.Is(err, errors.ErrUnsupported) {
+		return "ErrUnsupported"
+	}
+	if errors.Is(err, kv.ErrConditionFailed) {
+		return "ErrConditionFailed"
+	}
 	return err.Error()
 }
 
@@ -28,8 +43,44 @@ func string2error(str string) error {
 	if str == "ErrNotExist" {
 		return os.ErrNotExist
 	}
+	if str == "ErrPermission" {
+		return os.ErrPermission
+	}
 	if str == "EOF" {
 		return io.EOF
 	}
+	if str == "ErrConflict" {
+		return kv.ErrConflict
+	}
+	if str == "ErrUnsupported" {
+		return errors.ErrUnsupported
+	}
+	if str == "ErrConditionFailed" {
+		return kv.ErrConditionFailed
+	}
 	return errors.New(str)
 }
+
+func error2conflict(err error) *api.Conflict {
+	cerr := new(kv.ConflictError)
+	if !errors.As(err, &cerr) {
+		return nil
+	}
+	return &api.Conflict{
+		Key:            cerr.Key,
+		Reason:         string(cerr.Reason),
+		ReadVersion:    cerr.ReadVersion,
+		CurrentVersion: cerr.CurrentVersion,
+		TxVersion:      cerr.TxVersion,
+	}
+}
+
+func conflict2error(c *api.Conflict) error {
+	return &kv.ConflictError{
+		Key:            c.Key,
+		Reason:         kv.ConflictReason(c.Reason),
+		ReadVersion:    c.ReadVersion,
+		CurrentVersion: c.CurrentVersion,
+		TxVersion:      c.TxVersion,
+	}
+}
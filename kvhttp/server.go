@@ -62,24 +62,33 @@ func Handler(db kv.Database) http.Handler {
 	s.mux.Handle("/new-transaction", httpPostJSONHandler(s.newTransaction))
 	s.mux.Handle("/new-snap", httpPostJSONHandler(s.newSnapshot))
 	s.mux.Handle("/new-snapshot", httpPostJSONHandler(s.newSnapshot))
+	s.mux.Handle("/conditional-write", httpPostJSONHandler(s.conditionalWrite))
 
 	s.mux.Handle("/tx/get", httpPostJSONHandler(s.get))
 	s.mux.Handle("/tx/set", httpPostJSONHandler(s.set))
+	s.mux.Handle("/tx/multi-get", httpPostJSONHandler(s.multiGet))
+	s.mux.Handle("/tx/multi-set", httpPostJSONHandler(s.multiSet))
 	s.mux.Handle("/tx/del", httpPostJSONHandler(s.del))
 	s.mux.Handle("/tx/delete", httpPostJSONHandler(s.del))
+	s.mux.Handle("/tx/delete-range", httpPostJSONHandler(s.deleteRange))
 	s.mux.Handle("/tx/ascend", httpPostJSONHandler(s.ascend))
 	s.mux.Handle("/tx/descend", httpPostJSONHandler(s.descend))
 	s.mux.Handle("/tx/scan", httpPostJSONHandler(s.scan))
+	s.mux.Handle("/tx/count", httpPostJSONHandler(s.count))
 	s.mux.Handle("/tx/commit", httpPostJSONHandler(s.commit))
 	s.mux.Handle("/tx/rollback", httpPostJSONHandler(s.rollback))
 
 	s.mux.Handle("/snap/get", httpPostJSONHandler(s.get))
+	s.mux.Handle("/snap/multi-get", httpPostJSONHandler(s.multiGet))
 	s.mux.Handle("/snap/ascend", httpPostJSONHandler(s.ascend))
 	s.mux.Handle("/snap/descend", httpPostJSONHandler(s.descend))
 	s.mux.Handle("/snap/scan", httpPostJSONHandler(s.scan))
+	s.mux.Handle("/snap/count", httpPostJSONHandler(s.count))
 	s.mux.Handle("/snap/discard", httpPostJSONHandler(s.discard))
+	s.mux.Handle("/snap/history", httpPostJSONHandler(s.history))
 
 	s.mux.Handle("/it/fetch", httpPostJSONHandler(s.fetch))
+	s.mux.Handle("/it/seek", httpPostJSONHandler(s.seek))
 	return s.mux
 }
 
@@ -215,14 +224,43 @@ func (s *server) newTransaction(ctx context.Context, u *url.URL, req *api.NewTra
 		return nil, &statusErr{err: os.ErrExist, code: http.StatusConflict}
 	}
 
-	tx, err := s.db.NewTransaction(ctx)
+	opts := &kv.TransactionOptions{
+		ReadOnly:  req.ReadOnly,
+		Isolation: kv.IsolationLevel(req.Isolation),
+	}
+	tx, err := kv.NewTransaction(ctx, s.db, opts)
 	if err != nil {
 		s.deleteName(req.Name)
 		return &api.NewTransactionResponse{Error: error2string(err)}, nil
 	}
 
 	s.txMap.Store(id, tx)
-	return &api.NewTransactionResponse{}, nil
+
+	resp := &api.NewTransactionResponse{}
+	if v, ok := tx.(kv.CommitVersioner); ok {
+		resp.Version = v.ReadVersion()
+	}
+	return resp, nil
+}
+
+func (s *server) conditionalWrite(ctx context.Context, u *url.URL, req *api.ConditionalWriteRequest) (*api.ConditionalWriteResponse, error) {
+	var err error
+	switch req.Op {
+	case "compare-and-set":
+		err = kv.CompareAndSet(ctx, s.db, req.Key, bytes.NewReader(req.OldValue), bytes.NewReader(req.Value))
+	case "compare-version-and-set":
+		err = kv.CompareVersionAndSet(ctx, s.db, req.Key, req.OldVersion, bytes.NewReader(req.Value))
+	case "set-if-absent":
+		err = kv.SetIfAbsent(ctx, s.db, req.Key, bytes.NewReader(req.Value))
+	case "delete-if-equal":
+		err = kv.DeleteIfEqual(ctx, s.db, req.Key, bytes.NewReader(req.OldValue))
+	default:
+		return nil, &statusErr{err: os.ErrInvalid, code: http.StatusBadRequest}
+	}
+	if err != nil {
+		return &api.ConditionalWriteResponse{Error: error2string(err)}, nil
+	}
+	return &api.ConditionalWriteResponse{}, nil
 }
 
 func (s *server) set(ctx context.Context, u *url.URL, req *api.SetRequest) (*api.SetResponse, error) {
@@ -237,12 +275,44 @@ func (s *server) set(ctx context.Context, u *url.URL, req *api.SetRequest) (*api
 		return nil, &statusErr{err: os.ErrNotExist, code: http.StatusNotFound}
 	}
 
-	if err := tx.Set(ctx, req.Key, bytes.NewReader(req.Value)); err != nil {
+	var err error
+	if req.TTL > 0 {
+		if ts, ok := tx.(kv.TTLSetter); ok {
+			err = ts.SetWithTTL(ctx, req.Key, bytes.NewReader(req.Value), req.TTL)
+		} else {
+			err = errors.ErrUnsupported
+		}
+	} else {
+		err = tx.Set(ctx, req.Key, bytes.NewReader(req.Value))
+	}
+	if err != nil {
 		return &api.SetResponse{Error: error2string(err)}, nil
 	}
 	return &api.SetResponse{}, nil
 }
 
+func (s *server) multiSet(ctx context.Context, u *url.URL, req *api.MultiSetRequest) (*api.MultiSetResponse, error) {
+	id, ok := s.LockExisting(req.Transaction)
+	if !ok {
+		return nil, &statusErr{err: os.ErrNotExist, code: http.StatusNotFound}
+	}
+	defer s.Unlock(req.Transaction, false /* delete */)
+
+	tx, ok := s.txMap.Load(id)
+	if !ok {
+		return nil, &statusErr{err: os.ErrNotExist, code: http.StatusNotFound}
+	}
+
+	kvs := make(map[string]io.Reader, len(req.Values))
+	for k, v := range req.Values {
+		kvs[k] = bytes.NewReader(v)
+	}
+	if err := kv.SetMany(ctx, tx, kvs); err != nil {
+		return &api.MultiSetResponse{Error: error2string(err)}, nil
+	}
+	return &api.MultiSetResponse{}, nil
+}
+
 func (s *server) del(ctx context.Context, u *url.URL, req *api.DeleteRequest) (*api.DeleteResponse, error) {
 	id, ok := s.LockExisting(req.Transaction)
 	if !ok {
@@ -261,6 +331,24 @@ func (s *server) del(ctx context.Context, u *url.URL, req *api.DeleteRequest) (*
 	return &api.DeleteResponse{}, nil
 }
 
+func (s *server) deleteRange(ctx context.Context, u *url.URL, req *api.DeleteRangeRequest) (*api.DeleteRangeResponse, error) {
+	id, ok := s.LockExisting(req.Transaction)
+	if !ok {
+		return nil, &statusErr{err: os.ErrNotExist, code: http.StatusNotFound}
+	}
+	defer s.Unlock(req.Transaction, false /* delete */)
+
+	tx, ok := s.txMap.Load(id)
+	if !ok {
+		return nil, &statusErr{err: os.ErrNotExist, code: http.StatusNotFound}
+	}
+
+	if err := kv.DeleteRange(ctx, tx, req.Begin, req.End); err != nil {
+		return &api.DeleteRangeResponse{Error: error2string(err)}, nil
+	}
+	return &api.DeleteRangeResponse{}, nil
+}
+
 func (s *server) commit(ctx context.Context, u *url.URL, req *api.CommitRequest) (*api.CommitResponse, error) {
 	id, ok := s.LockExisting(req.Transaction)
 	if !ok {
@@ -289,9 +377,14 @@ func (s *server) commit(ctx context.Context, u *url.URL, req *api.CommitRequest)
 	}
 
 	if err := tx.Commit(ctx); err != nil {
-		return &api.CommitResponse{Error: error2string(err)}, nil
+		return &api.CommitResponse{Error: error2string(err), Conflict: error2conflict(err)}, nil
 	}
-	return &api.CommitResponse{}, nil
+
+	resp := &api.CommitResponse{}
+	if v, ok := tx.(kv.CommitVersioner); ok {
+		resp.Version, _ = v.CommitVersion()
+	}
+	return resp, nil
 }
 
 func (s *server) rollback(ctx context.Context, u *url.URL, req *api.RollbackRequest) (*api.RollbackResponse, error) {
@@ -334,14 +427,27 @@ func (s *server) newSnapshot(ctx context.Context, u *url.URL, req *api.NewSnapsh
 		return nil, &statusErr{err: os.ErrExist, code: http.StatusConflict}
 	}
 
-	snap, err := s.db.NewSnapshot(ctx)
+	var snap kv.Snapshot
+	var err error
+	if req.Version == nil {
+		snap, err = s.db.NewSnapshot(ctx)
+	} else if vdb, ok := s.db.(kv.VersionedDatabase); ok {
+		snap, err = vdb.NewSnapshotAt(ctx, *req.Version)
+	} else {
+		err = errors.ErrUnsupported
+	}
 	if err != nil {
 		s.deleteName(req.Name)
 		return &api.NewSnapshotResponse{Error: error2string(err)}, nil
 	}
 
 	s.snapMap.Store(id, snap)
-	return &api.NewSnapshotResponse{}, nil
+
+	resp := &api.NewSnapshotResponse{}
+	if v, ok := snap.(interface{ Version() int64 }); ok {
+		resp.Version = v.Version()
+	}
+	return resp, nil
 }
 
 func (s *server) discard(ctx context.Context, u *url.URL, req *api.DiscardRequest) (*api.DiscardResponse, error) {
@@ -376,6 +482,43 @@ func (s *server) discard(ctx context.Context, u *url.URL, req *api.DiscardReques
 	return &api.DiscardResponse{}, nil
 }
 
+func (s *server) history(ctx context.Context, u *url.URL, req *api.HistoryRequest) (*api.HistoryResponse, error) {
+	id, ok := s.LockExisting(req.Snapshot)
+	if !ok {
+		return nil, &statusErr{err: os.ErrNotExist, code: http.StatusNotFound}
+	}
+	defer s.Unlock(req.Snapshot, false /* delete */)
+
+	snap, ok := s.snapMap.Load(id)
+	if !ok {
+		return nil, &statusErr{err: os.ErrNotExist, code: http.StatusNotFound}
+	}
+
+	historian, ok := snap.(kv.Historian)
+	if !ok {
+		return &api.HistoryResponse{Error: error2string(errors.ErrUnsupported)}, nil
+	}
+	it, err := historian.History(ctx, req.Key)
+	if err != nil {
+		return &api.HistoryResponse{Error: error2string(err)}, nil
+	}
+	defer it.Close()
+
+	resp := new(api.HistoryResponse)
+	for c, err := it.Next(ctx); !errors.Is(err, io.EOF); c, err = it.Next(ctx) {
+		if err != nil {
+			return &api.HistoryResponse{Error: error2string(err)}, nil
+		}
+		resp.Changes = append(resp.Changes, &api.Change{
+			Key:     c.Key,
+			Value:   c.Value,
+			Deleted: c.Deleted,
+			Version: c.Version,
+		})
+	}
+	return resp, nil
+}
+
 func (s *server) get(ctx context.Context, u *url.URL, req *api.GetRequest) (*api.GetResponse, error) {
 	if len(req.Transaction) == 0 && len(req.Snapshot) == 0 {
 		return nil, &statusErr{err: os.ErrInvalid, code: http.StatusBadRequest}
@@ -422,6 +565,108 @@ func (s *server) get(ctx context.Context, u *url.URL, req *api.GetRequest) (*api
 	return &api.GetResponse{Value: data}, nil
 }
 
+func (s *server) multiGet(ctx context.Context, u *url.URL, req *api.MultiGetRequest) (*api.MultiGetResponse, error) {
+	if len(req.Transaction) == 0 && len(req.Snapshot) == 0 {
+		return nil, &statusErr{err: os.ErrInvalid, code: http.StatusBadRequest}
+	}
+	if len(req.Transaction) != 0 && len(req.Snapshot) != 0 {
+		return nil, &statusErr{err: os.ErrInvalid, code: http.StatusBadRequest}
+	}
+
+	var getter kv.Getter
+	if len(req.Transaction) != 0 {
+		id, ok := s.LockExisting(req.Transaction)
+		if !ok {
+			return nil, &statusErr{err: os.ErrNotExist, code: http.StatusNotFound}
+		}
+		defer s.Unlock(req.Transaction, false /* delete */)
+
+		tx, ok := s.txMap.Load(id)
+		if !ok {
+			return nil, &statusErr{err: os.ErrNotExist, code: http.StatusNotFound}
+		}
+		getter = tx
+	} else {
+		id, ok := s.LockExisting(req.Snapshot)
+		if !ok {
+			return nil, &statusErr{err: os.ErrNotExist, code: http.StatusNotFound}
+		}
+		defer s.Unlock(req.Snapshot, false /* delete */)
+
+		snap, ok := s.snapMap.Load(id)
+		if !ok {
+			return nil, &statusErr{err: os.ErrNotExist, code: http.StatusNotFound}
+		}
+		getter = snap
+	}
+
+	values, errs, err := kv.GetMany(ctx, getter, req.Keys)
+	if err != nil {
+		return &api.MultiGetResponse{Error: error2string(err)}, nil
+	}
+	resp := &api.MultiGetResponse{
+		Values: make([][]byte, len(req.Keys)),
+		Errors: make([]string, len(req.Keys)),
+	}
+	for i := range req.Keys {
+		if errs[i] != nil {
+			resp.Errors[i] = error2string(errs[i])
+			continue
+		}
+		if values[i] == nil {
+			continue
+		}
+		data, err := io.ReadAll(values[i])
+		if err != nil {
+			return nil, err
+		}
+		resp.Values[i] = data
+	}
+	return resp, nil
+}
+
+func (s *server) count(ctx context.Context, u *url.URL, req *api.CountRequest) (*api.CountResponse, error) {
+	if len(req.Transaction) == 0 && len(req.Snapshot) == 0 {
+		return nil, &statusErr{err: os.ErrInvalid, code: http.StatusBadRequest}
+	}
+	if len(req.Transaction) != 0 && len(req.Snapshot) != 0 {
+		return nil, &statusErr{err: os.ErrInvalid, code: http.StatusBadRequest}
+	}
+
+	var ranger kv.Ranger
+	if len(req.Transaction) != 0 {
+		id, ok := s.LockExisting(req.Transaction)
+		if !ok {
+			return nil, &statusErr{err: os.ErrNotExist, code: http.StatusNotFound}
+		}
+		defer s.Unlock(req.Transaction, false /* delete */)
+
+		tx, ok := s.txMap.Load(id)
+		if !ok {
+			return nil, &statusErr{err: os.ErrNotExist, code: http.StatusNotFound}
+		}
+		ranger = tx
+	} else {
+		id, ok := s.LockExisting(req.Snapshot)
+		if !ok {
+			return nil, &statusErr{err: os.ErrNotExist, code: http.StatusNotFound}
+		}
+		defer s.Unlock(req.Snapshot, false /* delete */)
+
+		snap, ok := s.snapMap.Load(id)
+		if !ok {
+			return nil, &statusErr{err: os.ErrNotExist, code: http.StatusNotFound}
+		}
+		ranger = snap
+	}
+
+	n, err := kv.Count(ctx, ranger, req.Begin, req.End)
+	if err != nil {
+		return &api.CountResponse{Error: error2string(err)}, nil
+	}
+	return &api.CountResponse{Count: n}, nil
+}
+
 func (s *server) ascend(ctx context.Context, u *url.URL, req *api.AscendRequest) (*api.AscendResponse, error) {
 	if len(req.Transaction) == 0 && len(req.Snapshot) == 0 {
 		return nil, &statusErr{err: os.ErrInvalid, code: http.StatusBadRequest}
@@ -473,7 +718,13 @@ func (s *server) ascend(ctx context.Context, u *url.URL, req *api.AscendRequest)
 		rangerItersMap = &s.snapItersMap
 	}
 
-	it, err := ranger.Ascend(ctx, req.Begin, req.End)
+	var it kv.Iterator
+	var err error
+	if req.KeysOnly {
+		it, err = kv.AscendKeys(ctx, ranger, req.Begin, req.End)
+	} else {
+		it, err = ranger.Ascend(ctx, req.Begin, req.End)
+	}
 	if err != nil {
 		s.deleteName(req.Name)
 		return &api.AscendResponse{Error: error2string(err)}, nil
@@ -539,7 +790,13 @@ func (s *server) descend(ctx context.Context, u *url.URL, req *api.DescendReques
 		rangerItersMap = &s.snapItersMap
 	}
 
-	it, err := ranger.Descend(ctx, req.Begin, req.End)
+	var it kv.Iterator
+	var err error
+	if req.KeysOnly {
+		it, err = kv.DescendKeys(ctx, ranger, req.Begin, req.End)
+	} else {
+		it, err = ranger.Descend(ctx, req.Begin, req.End)
+	}
 	if err != nil {
 		s.deleteName(req.Name)
 		return &api.DescendResponse{Error: error2string(err)}, nil
@@ -605,7 +862,13 @@ func (s *server) scan(ctx context.Context, u *url.URL, req *api.ScanRequest) (*a
 		scannerItersMap = &s.snapItersMap
 	}
 
-	it, err := scanner.Scan(ctx)
+	var it kv.Iterator
+	var err error
+	if req.KeysOnly {
+		it, err = kv.ScanKeys(ctx, scanner)
+	} else {
+		it, err = scanner.Scan(ctx)
+	}
 	if err != nil {
 		s.deleteName(req.Name)
 		return &api.ScanResponse{Error: error2string(err)}, nil
@@ -633,6 +896,9 @@ func (s *server) fetch(ctx context.Context, u *url.URL, req *api.FetchRequest) (
 	}
 	k, v, err := it.Fetch(ctx, req.Next)
 	if err == nil {
+		if v == nil {
+			return &api.FetchResponse{Key: k}, nil
+		}
 		data, err := io.ReadAll(v)
 		if err != nil {
 			return nil, err
@@ -641,3 +907,35 @@ func (s *server) fetch(ctx context.Context, u *url.URL, req *api.FetchRequest) (
 	}
 	return &api.FetchResponse{Error: error2string(err)}, nil
 }
+
+func (s *server) seek(ctx context.Context, u *url.URL, req *api.SeekRequest) (*api.SeekResponse, error) {
+	id, ok := s.LockExisting(req.Iterator)
+	if !ok {
+		return nil, &statusErr{err: os.ErrNotExist, code: http.StatusNotFound}
+	}
+	defer s.Unlock(req.Iterator, false /* delete */)
+
+	it, ok := s.itMap.Load(id)
+	if !ok {
+		return nil, &statusErr{err: os.ErrNotExist, code: http.StatusNotFound}
+	}
+	seeker, ok := it.(kv.Seeker)
+	if !ok {
+		return &api.SeekResponse{Error: error2string(errors.ErrUnsupported)}, nil
+	}
+	if err := seeker.Seek(ctx, req.Key); err != nil {
+		return &api.SeekResponse{Error: error2string(err)}, nil
+	}
+	k, v, err := it.Fetch(ctx, false)
+	if err == nil {
+		if v == nil {
+			return &api.SeekResponse{Key: k}, nil
+		}
+		data, err := io.ReadAll(v)
+		if err != nil {
+			return nil, err
+		}
+		return &api.SeekResponse{Key: k, Value: data}, nil
+	}
+	return &api.SeekResponse{Error: error2string(err)}, nil
+}
@@ -4,125 +4,560 @@ package kvmemdb
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/binary"
 	"encoding/gob"
 	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/bvkgo/kv/internal/multival"
 )
 
-type gobHeader struct {
+// Backups begin with a fixed size preamble followed by a stream of records
+// (see record.go), which is optionally compressed with gzip.
+//
+//	+------------+-------------+------------+------------------------------+
+//	| magic (8B) | format (1B) | flags (1B) | header, values..., trailer   |
+//	+------------+-------------+------------+------------------------------+
+//
+// Every record payload begins with a record type. Header record holds the
+// database versions, value records hold one key-value each in the increasing
+// order of keys, and the trailer record holds the number of value records, so
+// that truncated backups can be detected.
+//
+// Incremental backups are marked with a flag and their header record also
+// holds the base version. They include the values for all keys modified after
+// the base version, including the deleted keys.
+
+const (
+	backupMagic  = "KVMEMDB\x00"
+	backupFormat = 1
+)
+
+const (
+	backupCompressed = 1 << iota
+	backupIncremental
+)
+
+const (
+	recordHeader  = 'H'
+	recordValue   = 'V'
+	recordTrailer = 'T'
+)
+
+// BackupOptions holds the optional parameters for the backups.
+type BackupOptions struct {
+	// Compress enables gzip compression for the backup data.
+	Compress bool
+}
+
+// backupHeader holds the database versions saved in a backup.
+type backupHeader struct {
 	LastTxVersion    int64
 	MaxCommitVersion int64
+
+	// Incremental is true for the backups with only the changes after the
+	// SinceVersion.
+	Incremental  bool
+	SinceVersion int64
 }
 
-type gobValue struct {
-	Version int64
-	Data    []byte
-	Key     string
+// Backup saves database content to a file. File is replaced atomically, so
+// it either holds the previous content or the complete backup.
+func Backup(db *DB, file string) error {
+	return writeFileAtomic(file, func(w io.Writer) error {
+		return BackupTo(context.Background(), db, w, nil)
+	})
 }
 
-// func (v *gobValue) GobEncode() ([]byte, error) {
-// 	return []byte(fmt.Sprintf("%s %08d %s\n", v.Key, v.Version, v.Data)), nil
-// }
+// Restore loads database from a file. Backups from the older versions of
+// this package are also supported.
+func Restore(file string) (*DB, error) {
+	fp, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
 
-// func (v *gobValue) GobDecode(bs []byte) error {
-// 	_, err := fmt.Sscanf(string(bs), "%s %08d %s\n", &v.Key, &v.Version, &v.Data)
-// 	return err
-// }
+	db := New()
+	if err := restoreInto(context.Background(), db, bufio.NewReader(fp)); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
 
-// Backup saves database content to a file.
-func Backup(db *DB, file string) error {
-	fp, err := os.Create(file)
+// BackupTo writes a consistent copy of the database content to the writer.
+// A nil options value uses the defaults.
+func BackupTo(ctx context.Context, db *DB, w io.Writer, opts *BackupOptions) error {
+	s, header := db.backupSnapshot()
+	defer s.Discard(ctx)
+
+	return writeBackup(ctx, w, s, header, opts)
+}
+
+// BackupSince writes an incremental backup with the changes committed after
+// the input version, including the deleted keys, to the writer. Input version
+// is typically the version of a previous backup, which can be restored
+// together with the incremental backups using RestoreChain.
+//
+// Changes after the input version must still be retained by the database (see
+// Options.RetainVersions and Options.RetainDuration), otherwise, returns
+// os.ErrNotExist. Returns os.ErrInvalid if the version is not committed yet.
+func BackupSince(ctx context.Context, db *DB, w io.Writer, version int64, opts *BackupOptions) error {
+	// Keep the base version pinned, so that deleted keys are not removed
+	// from the store while it is scanned.
+	base, err := db.newSnapshotAt(version, false /* track */)
 	if err != nil {
 		return err
 	}
-	defer fp.Close()
+	defer base.Discard(ctx)
+
+	s, header := db.backupSnapshot()
+	defer s.Discard(ctx)
+
+	header.Incremental = true
+	header.SinceVersion = version
+	return writeBackup(ctx, w, s, header, opts)
+}
+
+// BackupVersion returns the database version saved in a backup written by
+// BackupTo or BackupSince. Backup is validated completely.
+func BackupVersion(ctx context.Context, r io.Reader) (int64, error) {
+	skip := func(string, *multival.Value) error { return nil }
+	header, err := readBackup(ctx, r, nil, skip)
+	if err != nil {
+		return 0, err
+	}
+	return header.MaxCommitVersion, nil
+}
+
+// RestoreFrom reads a backup written by BackupTo and returns a new database
+// with the backup content. Backup is validated completely before the
+// database is returned.
+func RestoreFrom(ctx context.Context, r io.Reader) (*DB, error) {
+	return RestoreChain(ctx, r)
+}
+
+// RestoreChain returns a new database with the content of a full backup
+// written by BackupTo followed by zero or more incremental backups written by
+// BackupSince, in that order. Every incremental backup must begin at or before
+// the version of the previous backup.
+func RestoreChain(ctx context.Context, rs ...io.Reader) (*DB, error) {
+	if len(rs) == 0 {
+		return nil, fmt.Errorf("at least one backup is required: %w", os.ErrInvalid)
+	}
 
-	bufp := bufio.NewWriter(fp)
-	encoder := gob.NewEncoder(bufp)
+	db := New()
+	header, err := readBackup(ctx, rs[0], fullBackupOnly, db.restoreValue)
+	if err != nil {
+		return nil, err
+	}
+	db.restoreHeader(header)
+
+	for i, r := range rs[1:] {
+		accept := func(h *backupHeader) error {
+			if !h.Incremental {
+				return fmt.Errorf("backup %d is not an incremental backup: %w", i+1, os.ErrInvalid)
+			}
+			if h.SinceVersion > db.maxCommitVersion || h.MaxCommitVersion < db.maxCommitVersion {
+				return fmt.Errorf("backup %d with versions (%d, %d] doesn't follow version %d: %w", i+1, h.SinceVersion, h.MaxCommitVersion, db.maxCommitVersion, os.ErrInvalid)
+			}
+			return nil
+		}
+		header, err := readBackup(ctx, r, accept, db.restoreValue)
+		if err != nil {
+			return nil, err
+		}
+		db.restoreHeader(header)
+	}
+	return db, nil
+}
 
-	snap, _ := db.NewSnapshot(context.Background())
-	s := snap.(*Snapshot)
-	defer s.Discard(context.Background())
+// VerifyBackup reads a backup written by BackupTo or BackupSince completely
+// and returns a non-nil error if the backup is corrupted, truncated or is not
+// a backup.
+func VerifyBackup(ctx context.Context, r io.Reader) error {
+	_, err := BackupVersion(ctx, r)
+	return err
+}
 
+// backupSnapshot returns a new snapshot and the backup header for the
+// snapshot version.
+func (db *DB) backupSnapshot() (*Snapshot, *backupHeader) {
 	db.mu.Lock()
-	header := gobHeader{
+	defer db.mu.Unlock()
+
+	s := &Snapshot{
+		db:                db,
+		lastCommitVersion: db.maxCommitVersion,
+		readTime:          time.Now().UnixNano(),
+	}
+	db.pinLocked(db.maxCommitVersion, s.readTime)
+
+	header := &backupHeader{
 		LastTxVersion:    db.lastTxVersion,
 		MaxCommitVersion: db.maxCommitVersion,
 	}
-	db.mu.Unlock()
+	return s, header
+}
 
-	if err := encoder.Encode(header); err != nil {
+// writeBackup saves the header and the key-values visible to the snapshot.
+func writeBackup(ctx context.Context, w io.Writer, s *Snapshot, header *backupHeader, opts *BackupOptions) error {
+	var flags byte
+	if opts != nil && opts.Compress {
+		flags |= backupCompressed
+	}
+	if header.Incremental {
+		flags |= backupIncremental
+	}
+	preamble := append([]byte(backupMagic), backupFormat, flags)
+	if _, err := w.Write(preamble); err != nil {
+		return err
+	}
+
+	bufw := bufio.NewWriter(w)
+	var out io.Writer = bufw
+	var zw *gzip.Writer
+	if flags&backupCompressed != 0 {
+		zw = gzip.NewWriter(bufw)
+		out = zw
+	}
+
+	var buf []byte
+	buf = append(buf, recordHeader)
+	buf = binary.AppendVarint(buf, header.LastTxVersion)
+	buf = binary.AppendVarint(buf, header.MaxCommitVersion)
+	if header.Incremental {
+		buf = binary.AppendVarint(buf, header.SinceVersion)
+	}
+	if _, err := writeRecord(out, buf); err != nil {
 		return err
 	}
 
 	var status error
+	var count uint64
 	save := func(key string, mval *multival.MultiValue) bool {
-		if v, ok := mval.Fetch(s.lastCommitVersion); ok && !v.Deleted {
-			gv := &gobValue{
-				Key:     key,
-				Data:    v.Data,
-				Version: v.Version,
-			}
-			if err := encoder.Encode(gv); err != nil {
+		if count%1024 == 0 {
+			if err := ctx.Err(); err != nil {
 				status = err
 				return false
 			}
 		}
+		v, ok := mval.Fetch(s.lastCommitVersion)
+		if !ok {
+			return true
+		}
+		if header.Incremental {
+			if v.Version <= header.SinceVersion {
+				return true
+			}
+		} else if !v.Visible(s.readTime) {
+			return true
+		}
+		buf = appendValue(append(buf[:0], recordValue), key, v)
+		if _, err := writeRecord(out, buf); err != nil {
+			status = err
+			return false
+		}
+		count++
 		return true
 	}
-	db.store.Range(save)
+	s.db.store.Range(save)
 	if status != nil {
 		return fmt.Errorf("could not complete db scan: %w", status)
 	}
 
-	if err := bufp.Flush(); err != nil {
+	buf = binary.AppendUvarint(append(buf[:0], recordTrailer), count)
+	if _, err := writeRecord(out, buf); err != nil {
 		return err
 	}
-	if err := fp.Sync(); err != nil {
-		return err
+
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return err
+		}
 	}
-	return nil
+	return bufw.Flush()
 }
 
-// Restore loads database from a file.
-func Restore(file string) (*DB, error) {
-	fp, err := os.Open(file)
+// readBackup reads and validates a backup written by the writeBackup
+// function. Backup header is passed to the accept function, when non-nil,
+// before any values are read. Values are passed to the apply function in the
+// increasing order of keys.
+func readBackup(ctx context.Context, r io.Reader, accept func(*backupHeader) error, apply func(key string, v *multival.Value) error) (*backupHeader, error) {
+	preamble := make([]byte, len(backupMagic)+2)
+	if _, err := io.ReadFull(r, preamble); err != nil {
+		return nil, fmt.Errorf("could not read backup preamble: %w", err)
+	}
+	if string(preamble[:len(backupMagic)]) != backupMagic {
+		return nil, fmt.Errorf("input is not a backup: %w", os.ErrInvalid)
+	}
+	if format := preamble[len(backupMagic)]; format != backupFormat {
+		return nil, fmt.Errorf("backup format %d: %w", format, errors.ErrUnsupported)
+	}
+	flags := preamble[len(backupMagic)+1]
+	if flags&^(backupCompressed|backupIncremental) != 0 {
+		return nil, fmt.Errorf("backup flags %#x: %w", flags, errors.ErrUnsupported)
+	}
+
+	in := bufio.NewReader(r)
+	if flags&backupCompressed != 0 {
+		zr, err := gzip.NewReader(in)
+		if err != nil {
+			return nil, fmt.Errorf("could not read compressed backup: %w", err)
+		}
+		defer zr.Close()
+		in = bufio.NewReader(zr)
+	}
+
+	next := func() ([]byte, error) {
+		payload, err := readRecord(in)
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("backup is truncated: %w", io.ErrUnexpectedEOF)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read backup record: %w", err)
+		}
+		if len(payload) == 0 {
+			return nil, fmt.Errorf("empty backup record: %w", errChecksum)
+		}
+		return payload, nil
+	}
+
+	payload, err := next()
 	if err != nil {
 		return nil, err
 	}
-	defer fp.Close()
+	if payload[0] != recordHeader {
+		return nil, fmt.Errorf("unexpected record type %q for the header: %w", payload[0], os.ErrInvalid)
+	}
+	d := &decoder{buf: payload[1:]}
+	header := &backupHeader{
+		LastTxVersion:    d.varint(),
+		MaxCommitVersion: d.varint(),
+		Incremental:      flags&backupIncremental != 0,
+	}
+	if header.Incremental {
+		header.SinceVersion = d.varint()
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("could not decode backup header: %w", d.err)
+	}
+	if accept != nil {
+		if err := accept(header); err != nil {
+			return nil, err
+		}
+	}
 
-	db := New()
+	var count uint64
+	var lastKey string
+	for {
+		if count%1024 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		payload, err := next()
+		if err != nil {
+			return nil, err
+		}
+
+		switch payload[0] {
+		case recordValue:
+			d := &decoder{buf: payload[1:]}
+			key, value := d.value()
+			if d.err != nil {
+				return nil, fmt.Errorf("could not decode backup value: %w", d.err)
+			}
+			if count > 0 && key <= lastKey {
+				return nil, fmt.Errorf("backup key %q is out of order: %w", key, os.ErrInvalid)
+			}
+			if value.Version > header.MaxCommitVersion {
+				return nil, fmt.Errorf("backup key %q has version %d beyond the backup version %d: %w", key, value.Version, header.MaxCommitVersion, os.ErrInvalid)
+			}
+			if header.Incremental && value.Version <= header.SinceVersion {
+				return nil, fmt.Errorf("backup key %q has version %d before the base version %d: %w", key, value.Version, header.SinceVersion, os.ErrInvalid)
+			}
+			if !header.Incremental && value.Deleted {
+				return nil, fmt.Errorf("full backup has a deleted key %q: %w", key, os.ErrInvalid)
+			}
+			if err := apply(key, value); err != nil {
+				return nil, err
+			}
+			lastKey = key
+			count++
+
+		case recordTrailer:
+			d := &decoder{buf: payload[1:]}
+			if n := d.uvarint(); d.err != nil || n != count {
+				return nil, fmt.Errorf("backup has %d values, but trailer doesn't match: %w", count, os.ErrInvalid)
+			}
+			// Trailer must be the last record. Reading till the end also verifies
+			// the gzip checksum for the compressed backups.
+			if _, err := readRecord(in); !errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("unexpected data after the backup trailer: %w", os.ErrInvalid)
+			}
+			return header, nil
+
+		default:
+			return nil, fmt.Errorf("unexpected backup record type %q: %w", payload[0], os.ErrInvalid)
+		}
+	}
+}
+
+// fullBackupOnly rejects the incremental backups.
+func fullBackupOnly(h *backupHeader) error {
+	if h.Incremental {
+		return fmt.Errorf("incremental backup needs a full backup to restore: %w", os.ErrInvalid)
+	}
+	return nil
+}
+
+// restoreValue adds a value from the backup to the database or removes the
+// key if the value is deleted. Database must not be in use.
+func (db *DB) restoreValue(key string, v *multival.Value) error {
+	if v.Deleted {
+		if mv, ok := db.store.LoadAndDelete(key); ok {
+			db.updateCountsLocked(key, mv, nil)
+		}
+		return nil
+	}
+	mv, _ := db.store.Load(key)
+	newmv := multival.Append(nil, v)
+	db.store.Store(key, newmv)
+	db.updateCountsLocked(key, mv, newmv)
+	return nil
+}
+
+// restoreInto loads the backup data into an empty database. Database versions
+// are set to the versions from the backup header.
+func restoreInto(ctx context.Context, db *DB, r *bufio.Reader) error {
+	var header *backupHeader
+	if magic, err := r.Peek(len(backupMagic)); err == nil && bytes.Equal(magic, []byte(backupMagic)) {
+		h, err := readBackup(ctx, r, fullBackupOnly, db.restoreValue)
+		if err != nil {
+			return err
+		}
+		header = h
+	} else {
+		h, err := readGobBackup(db, r)
+		if err != nil {
+			return err
+		}
+		header = h
+	}
+
+	db.restoreHeader(header)
+	return nil
+}
+
+// restoreHeader sets the database versions from a backup header. Database
+// must not be in use.
+func (db *DB) restoreHeader(header *backupHeader) {
+	db.lastTxVersion = header.LastTxVersion
+	db.maxCommitVersion = header.MaxCommitVersion
+	// Restored database has only one version per key and no change history.
+	db.horizon = db.maxCommitVersion
+	db.changes = nil
+	db.changesFrom = db.maxCommitVersion + 1
+}
+
+// writeFileAtomic creates or replaces a file with the data written by the
+// input function. Data is written to a temporary file first, which is renamed
+// to the target file only after it is synced to the disk.
+func writeFileAtomic(file string, write func(io.Writer) error) (status error) {
+	dir, base := filepath.Split(file)
+	if dir == "" {
+		dir = "."
+	}
+	fp, err := createTemp(dir, base)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if status != nil {
+			fp.Close()
+			os.Remove(fp.Name())
+		}
+	}()
 
-	dec := gob.NewDecoder(bufio.NewReader(fp))
+	if err := write(fp); err != nil {
+		return err
+	}
+	if err := fp.Sync(); err != nil {
+		return err
+	}
+	if err := fp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(fp.Name(), file); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// createTemp creates a new temporary file in the directory. Unlike
+// os.CreateTemp, file is created with the same permissions as os.Create,
+// which are 0666 before the umask.
+func createTemp(dir, base string) (*os.File, error) {
+	for i := 0; ; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("%s.tmp%d", base, rand.Uint32()))
+		fp, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if errors.Is(err, os.ErrExist) && i < 100 {
+			continue
+		}
+		return fp, err
+	}
+}
+
+// gobHeader and gobValue are the backup types used by the older versions of
+// this package.
+
+type gobHeader struct {
+	LastTxVersion    int64
+	MaxCommitVersion int64
+}
+
+type gobValue struct {
+	Version int64
+	Data    []byte
+	Key     string
+}
+
+// readGobBackup loads the older gob backup format into an empty database.
+func readGobBackup(db *DB, r io.Reader) (*backupHeader, error) {
+	dec := gob.NewDecoder(r)
 	header := new(gobHeader)
 	if err := dec.Decode(header); err != nil {
 		return nil, err
 	}
 
+	var err error
 	gv := new(gobValue)
 	for err = dec.Decode(gv); err == nil; err = dec.Decode(gv) {
-		if db.maxCommitVersion < gv.Version {
-			db.maxCommitVersion = gv.Version
-		}
 		v := &multival.Value{
 			Version: gv.Version,
 			Data:    gv.Data,
 		}
-		mv := multival.Append(nil, v)
-		db.store.Store(gv.Key, mv)
+		db.restoreValue(gv.Key, v)
 		*gv = gobValue{}
 	}
 	if err != nil && !errors.Is(err, io.EOF) {
 		return nil, err
 	}
 
-	db.lastTxVersion = header.LastTxVersion + 1
-	db.maxCommitVersion = header.MaxCommitVersion + 1
-	return db, nil
+	// Older backups didn't include the backup version in the header, so
+	// versions are advanced to be newer than all restored values.
+	return &backupHeader{
+		LastTxVersion:    header.LastTxVersion + 1,
+		MaxCommitVersion: header.MaxCommitVersion + 1,
+	}, nil
 }
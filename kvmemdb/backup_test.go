@@ -0,0 +1,273 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kvmemdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bvkgo/kv"
+)
+
+func newBackupTestDB(ctx context.Context, t *testing.T) (*DB, map[string]string) {
+	db := New()
+	for i := 0; i < 100; i++ {
+		set := func(ctx context.Context, rw kv.ReadWriter) error {
+			key := fmt.Sprintf("key%02d", i%40)
+			if i%7 == 0 {
+				if err := rw.Delete(ctx, key); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return err
+				}
+				return nil
+			}
+			return rw.Set(ctx, key, strings.NewReader(strings.Repeat("x", i)))
+		}
+		if err := kv.WithReadWriter(ctx, db, set); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := dumpDB(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, want
+}
+
+func TestBackupRestore(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	db, want := newBackupTestDB(ctx, t)
+
+	for _, compress := range []bool{false, true} {
+		var buf bytes.Buffer
+		if err := BackupTo(ctx, db, &buf, &BackupOptions{Compress: compress}); err != nil {
+			t.Fatal(err)
+		}
+		backup := buf.Bytes()
+
+		if err := VerifyBackup(ctx, bytes.NewReader(backup)); err != nil {
+			t.Fatalf("compress=%t: %v", compress, err)
+		}
+		rdb, err := RestoreFrom(ctx, bytes.NewReader(backup))
+		if err != nil {
+			t.Fatalf("compress=%t: %v", compress, err)
+		}
+		got, err := dumpDB(ctx, rdb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !maps.Equal(got, want) {
+			t.Fatalf("compress=%t: want %v, got %v", compress, want, got)
+		}
+		if rdb.maxCommitVersion != db.maxCommitVersion {
+			t.Fatalf("compress=%t: want version %d, got %d", compress, db.maxCommitVersion, rdb.maxCommitVersion)
+		}
+
+		// Every truncated backup must be rejected.
+		for i := 0; i < len(backup); i++ {
+			if err := VerifyBackup(ctx, bytes.NewReader(backup[:i])); err == nil {
+				t.Fatalf("compress=%t: backup truncated at %d/%d is accepted", compress, i, len(backup))
+			}
+		}
+
+		// Every corrupted byte must be detected. Gzip header has unchecked
+		// fields, so only the uncompressed backups are checked.
+		for i := 0; !compress && i < len(backup); i++ {
+			corrupt := bytes.Clone(backup)
+			corrupt[i] ^= 0x55
+			if _, err := RestoreFrom(ctx, bytes.NewReader(corrupt)); err == nil {
+				t.Fatalf("compress=%t: backup corrupted at %d/%d is accepted", compress, i, len(backup))
+			}
+		}
+	}
+}
+
+func TestBackupFile(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db, want := newBackupTestDB(ctx, t)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "backup.db")
+	if err := Backup(db, file); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := os.ReadDir(dir); err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 1 {
+		t.Fatalf("want only the backup file, got %d entries", len(entries))
+	}
+
+	// Backup files have the same permissions as the files from os.Create.
+	fp, err := os.Create(filepath.Join(dir, "created"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp.Close()
+	if want, err := os.Stat(fp.Name()); err != nil {
+		t.Fatal(err)
+	} else if got, err := os.Stat(file); err != nil {
+		t.Fatal(err)
+	} else if got.Mode() != want.Mode() {
+		t.Fatalf("want mode %v, got %v", want.Mode(), got.Mode())
+	}
+
+	rdb, err := Restore(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := dumpDB(ctx, rdb); err != nil {
+		t.Fatal(err)
+	} else if !maps.Equal(got, want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+
+	// Backups in the older gob format must be restored.
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	if err := enc.Encode(&gobHeader{LastTxVersion: 10, MaxCommitVersion: 5}); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range want {
+		if err := enc.Encode(&gobValue{Key: k, Data: []byte(v), Version: 5}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	legacy := filepath.Join(dir, "legacy.db")
+	if err := os.WriteFile(legacy, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rdb, err = Restore(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := dumpDB(ctx, rdb); err != nil {
+		t.Fatal(err)
+	} else if !maps.Equal(got, want) {
+		t.Fatalf("legacy: want %v, got %v", want, got)
+	}
+
+	// Non-backup data must be rejected.
+	if _, err := RestoreFrom(ctx, io.LimitReader(strings.NewReader(strings.Repeat("garbage", 10)), 70)); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("want ErrInvalid, got %v", err)
+	}
+}
+
+func TestBackupSince(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := NewWithOptions(&Options{RetainVersions: 1000})
+
+	update := func(begin, end int) {
+		for i := begin; i < end; i++ {
+			set := func(ctx context.Context, rw kv.ReadWriter) error {
+				key := fmt.Sprintf("key%02d", i%30)
+				if i%3 == 0 {
+					if err := rw.Delete(ctx, key); err != nil && !errors.Is(err, os.ErrNotExist) {
+						return err
+					}
+					return nil
+				}
+				return rw.Set(ctx, key, strings.NewReader(fmt.Sprint(i)))
+			}
+			if err := kv.WithReadWriter(ctx, db, set); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Takes a full backup followed by two incremental backups.
+	var full, delta1, delta2, delta12 bytes.Buffer
+	update(0, 50)
+	if err := BackupTo(ctx, db, &full, nil); err != nil {
+		t.Fatal(err)
+	}
+	v0, err := BackupVersion(ctx, bytes.NewReader(full.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	update(50, 60)
+	if err := BackupSince(ctx, db, &delta1, v0, nil); err != nil {
+		t.Fatal(err)
+	}
+	v1, err := BackupVersion(ctx, bytes.NewReader(delta1.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	update(60, 70)
+	if err := BackupSince(ctx, db, &delta2, v1, &BackupOptions{Compress: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := BackupSince(ctx, db, &delta12, v0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if delta1.Len() >= full.Len() {
+		t.Errorf("incremental backup size %d is not smaller than the full backup size %d", delta1.Len(), full.Len())
+	}
+
+	want, err := dumpDB(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chains := [][]*bytes.Buffer{
+		{&full, &delta1, &delta2},
+		{&full, &delta12},
+		{&full, &delta1, &delta12},
+	}
+	for i, chain := range chains {
+		var rs []io.Reader
+		for _, b := range chain {
+			rs = append(rs, bytes.NewReader(b.Bytes()))
+		}
+		rdb, err := RestoreChain(ctx, rs...)
+		if err != nil {
+			t.Fatalf("chain %d: %v", i, err)
+		}
+		if got, err := dumpDB(ctx, rdb); err != nil {
+			t.Fatal(err)
+		} else if !maps.Equal(got, want) {
+			t.Fatalf("chain %d: want %v, got %v", i, want, got)
+		}
+		if rdb.maxCommitVersion != db.maxCommitVersion {
+			t.Fatalf("chain %d: want version %d, got %d", i, db.maxCommitVersion, rdb.maxCommitVersion)
+		}
+	}
+
+	// Chains with missing backups must be rejected.
+	if _, err := RestoreChain(ctx, bytes.NewReader(full.Bytes()), bytes.NewReader(delta2.Bytes())); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("want ErrInvalid for a gap, got %v", err)
+	}
+	if _, err := RestoreFrom(ctx, bytes.NewReader(delta1.Bytes())); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("want ErrInvalid for an incremental backup, got %v", err)
+	}
+
+	// Changes must be retained for the incremental backups.
+	ndb := New()
+	for i := 0; i < 10; i++ {
+		set := func(ctx context.Context, rw kv.ReadWriter) error {
+			return rw.Set(ctx, "key", strings.NewReader(fmt.Sprint(i)))
+		}
+		if err := kv.WithReadWriter(ctx, ndb, set); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := BackupSince(ctx, ndb, io.Discard, 1, nil); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("want ErrNotExist, got %v", err)
+	}
+}
@@ -4,9 +4,16 @@ package kvmemdb
 
 import (
 	"context"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/bvkgo/kv"
 	"github.com/bvkgo/kv/kvtests"
 )
 
@@ -29,3 +36,153 @@ func TestTxSemantics(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestConflictRetry(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := New()
+
+	increment := func(ctx context.Context, rw kv.ReadWriter) error {
+		var count int
+		if v, err := rw.Get(ctx, "counter"); err == nil {
+			data, err := io.ReadAll(v)
+			if err != nil {
+				return err
+			}
+			if count, err = strconv.Atoi(string(data)); err != nil {
+				return err
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return rw.Set(ctx, "counter", strings.NewReader(strconv.Itoa(count+1)))
+	}
+
+	// Without retries, concurrent increments must fail with ErrConflict.
+	tx1, _ := db.NewTransaction(ctx)
+	tx2, _ := db.NewTransaction(ctx)
+	if err := increment(ctx, tx1); err != nil {
+		t.Fatal(err)
+	}
+	if err := increment(ctx, tx2); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx1.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	err := tx2.Commit(ctx)
+	if !errors.Is(err, kv.ErrConflict) {
+		t.Fatalf("want ErrConflict, got %v", err)
+	}
+	if cerr := new(kv.ConflictError); !errors.As(err, &cerr) {
+		t.Fatalf("want a ConflictError, got %v", err)
+	} else if cerr.Key != "counter" || cerr.Reason != kv.ConflictCreated || cerr.ReadVersion != 0 || cerr.CurrentVersion == 0 {
+		t.Fatalf("unexpected conflict error details %#v", cerr)
+	}
+
+	const nworkers, nincrements = 10, 20
+	opts := &kv.RetryOptions{MaxAttempts: -1}
+
+	var wg sync.WaitGroup
+	errs := make([]error, nworkers)
+	for i := 0; i < nworkers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < nincrements; j++ {
+				if err := kv.WithReadWriterRetry(ctx, db, opts, increment); err != nil {
+					errs[i] = err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+
+	v, err := snap.Get(ctx, "counter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(v)
+	if want := strconv.Itoa(1 + nworkers*nincrements); string(data) != want {
+		t.Fatalf("want %s, got %s", want, data)
+	}
+}
+
+func TestSnapshotIsolation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := NewWithOptions(&Options{Isolation: kv.SnapshotIsolation})
+
+	// Write-skew anomaly is allowed under snapshot isolation.
+	template := `
+  db:db1  new-transaction               => tx:init
+  tx:init set key:account1 value:100
+  tx:init set key:account2 value:100
+  tx:init commit
+
+  db:db1  new-transaction               => tx:tx1
+  db:db1  new-transaction               => tx:tx2
+
+  tx:tx1  get key:account1              => value:100
+  tx:tx1  get key:account2              => value:100
+  tx:tx1  set key:account1 value:-100
+
+  tx:tx2  get key:account1              => value:100
+  tx:tx2  get key:account2              => value:100
+  tx:tx2  set key:account2 value:-100
+
+  tx:tx1  commit
+  tx:tx2  commit
+
+  db:db1  new-transaction               => tx:tx3
+  db:db1  new-transaction               => tx:tx4
+
+  tx:tx3  set key:account1 value:0
+  tx:tx4  set key:account1 value:1
+
+  tx:tx3  commit
+  tx:tx4  commit                        => error:ErrConflict
+`
+	if err := kvtests.RunTemplate(ctx, template, db); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPhantomInserts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := New()
+
+	// Both transactions check that there are no keys with "user/" prefix and
+	// insert one if it is absent. Only one of them must succeed under the
+	// serializable isolation.
+	template := `
+  db:db1  new-transaction               => tx:tx1
+  db:db1  new-transaction               => tx:tx2
+
+  tx:tx1  ascend begin:user/ end:user0  => it:it1
+  it:it1  fetch next:false              => error:EOF
+  tx:tx1  set key:user/a value:a
+
+  tx:tx2  ascend begin:user/ end:user0  => it:it2
+  it:it2  fetch next:false              => error:EOF
+  tx:tx2  set key:user/b value:b
+
+  tx:tx1  commit
+  tx:tx2  commit                        => error:ErrConflict
+`
+	if err := kvtests.RunTemplate(ctx, template, db); err != nil {
+		t.Fatal(err)
+	}
+}
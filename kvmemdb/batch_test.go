@@ -0,0 +1,68 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/bvkgo/kv"
+)
+
+func TestMultiGetSet(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := New()
+	tx, err := db.NewTransaction(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kvs := map[string]io.Reader{
+		"a": strings.NewReader("1"),
+		"b": strings.NewReader("2"),
+	}
+	if err := kv.SetMany(ctx, tx, kvs); err != nil {
+		t.Fatal(err)
+	}
+
+	// Transaction is unchanged when any value cannot be read.
+	bad := map[string]io.Reader{
+		"c": strings.NewReader("3"),
+		"d": iotest.ErrReader(os.ErrClosed),
+	}
+	if err := kv.SetMany(ctx, tx, bad); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("want ErrClosed, got %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := db.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Discard(ctx)
+
+	values, errs, err := kv.GetMany(ctx, snap, []string{"b", "c", "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(errs[1], os.ErrNotExist) {
+		t.Fatalf("want ErrNotExist for the missing key, got %v", errs[1])
+	}
+	for i, want := range map[int]string{0: "2", 2: "1"} {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if data, _ := io.ReadAll(values[i]); string(data) != want {
+			t.Fatalf("key %d: want %s, got %s", i, want, data)
+		}
+	}
+}
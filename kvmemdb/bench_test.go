@@ -0,0 +1,125 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kvmemdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/bvkgo/kv"
+	"github.com/bvkgo/kv/internal/multival"
+)
+
+const benchKeys = 1000000
+
+var benchDB = sync.OnceValue(func() *DB {
+	ctx := context.Background()
+	db := New()
+	for i := 0; i < benchKeys; i += 10000 {
+		fill := func(ctx context.Context, rw kv.ReadWriter) error {
+			for j := i; j < i+10000; j++ {
+				if err := rw.Set(ctx, benchKey(j), bytes.NewReader([]byte("value"))); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if err := kv.WithReadWriter(ctx, db, fill); err != nil {
+			panic(err)
+		}
+	}
+	return db
+})
+
+func benchKey(i int) string {
+	return fmt.Sprintf("key%08d", i)
+}
+
+func benchRange(b *testing.B, r kv.Ranger, descending bool) {
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		begin := (i * 7919) % (benchKeys - 10)
+		var it kv.Iterator
+		var err error
+		if descending {
+			it, err = r.Descend(ctx, benchKey(begin), benchKey(begin+10))
+		} else {
+			it, err = r.Ascend(ctx, benchKey(begin), benchKey(begin+10))
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+		n := 0
+		for _, _, err := it.Fetch(ctx, false); err == nil; _, _, err = it.Fetch(ctx, true) {
+			n++
+		}
+		if n != 10 {
+			b.Fatalf("want 10 keys, got %d", n)
+		}
+	}
+}
+
+// BenchmarkSnapshotAscend10 measures the cost of a ten key range query in a
+// database with a million keys.
+func BenchmarkSnapshotAscend10(b *testing.B) {
+	db := benchDB()
+	snap, _ := db.NewSnapshot(context.Background())
+	defer snap.Discard(context.Background())
+
+	benchRange(b, snap, false /* descending */)
+}
+
+func BenchmarkSnapshotDescend10(b *testing.B) {
+	db := benchDB()
+	snap, _ := db.NewSnapshot(context.Background())
+	defer snap.Discard(context.Background())
+
+	benchRange(b, snap, true /* descending */)
+}
+
+func BenchmarkTransactionAscend10(b *testing.B) {
+	db := benchDB()
+	tx, _ := db.NewTransactionWithOptions(context.Background(), &kv.TransactionOptions{Isolation: kv.SnapshotIsolation})
+	defer tx.Rollback(context.Background())
+
+	benchRange(b, tx, false /* descending */)
+}
+
+// BenchmarkSortedKeysAscend10 measures the same range query as the
+// BenchmarkSnapshotAscend10 when keys are collected and sorted for every
+// iterator, which was the approach before the ordered index.
+func BenchmarkSortedKeysAscend10(b *testing.B) {
+	ctx := context.Background()
+	db := benchDB()
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+
+	s := snap.(*Snapshot)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var keys []string
+		db.store.Range(func(key string, _ *multival.MultiValue) bool {
+			keys = append(keys, key)
+			return true
+		})
+		sort.Strings(keys)
+
+		begin := (i * 7919) % (benchKeys - 10)
+		lo, _ := slices.BinarySearch(keys, benchKey(begin))
+		hi, _ := slices.BinarySearch(keys, benchKey(begin+10))
+		for _, key := range keys[lo:hi] {
+			v, err := s.Get(ctx, key)
+			if err != nil {
+				b.Fatal(err)
+			}
+			io.Copy(io.Discard, v)
+		}
+	}
+}
@@ -3,11 +3,12 @@
 package kvmemdb
 
 import (
-	"context"
 	"fmt"
 	"math"
 	"os"
+	"time"
 
+	"github.com/bvkgo/kv"
 	"github.com/bvkgo/kv/internal/multival"
 )
 
@@ -19,102 +20,196 @@ func (db *DB) commit(tx *Transaction) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	minVersion := db.maxCommitVersion
-	for k := range db.pins {
-		if k < minVersion {
-			minVersion = k
-		}
+	if db.closed {
+		return os.ErrClosed
+	}
+
+	minVersion := db.minVersionLocked()
+	if err := tx.handle.check(); err != nil {
+		return err
 	}
 
-	// Check that all items accessed are unmodified in the database.
+	// Check that all items accessed are unmodified in the database. Snapshot
+	// isolation transactions check only for the items written.
 	for key, txval := range tx.accesses {
+		if tx.isolation == kv.SnapshotIsolation && txval.Version != tx.version {
+			continue
+		}
 		if mv, ok := db.store.Load(key); ok {
 			curval, cok := mv.Fetch(math.MaxInt64)
 			begval, bok := mv.Fetch(tx.lastCommitVersion)
-			// log.Printf("precommit %v key %s min-ver %d max-ver %d last-ver %d tx-ver %d curval %v begval %v txval %v", tx, key, minVersion, db.maxCommitVersion, tx.lastCommitVersion, tx.version, curval, begval, txval)
+			// log.Printf("precommit %v key %s min-ver %d max-ver %d last-ver %d tx-ver %d curval %v begval %v", tx, key, minVersion, db.maxCommitVersion, tx.lastCommitVersion, tx.version, curval, begval)
 
 			if !bok && !cok {
 				continue // new key solely by this tx
 			}
-			if !bok && cok {
-				return fmt.Errorf("precommit: %v key %q is also created by another tx", tx, key)
+			if bok && cok && curval.Version == begval.Version {
+				continue
 			}
-			if bok && !cok {
-				return fmt.Errorf("precommit: %v key %q is deleted by another tx", tx, key)
+			db.numConflicts.Add(1)
+			return fmt.Errorf("precommit: %v: %w", tx, newConflictError(tx, key, begval, curval))
+		}
+	}
+
+	// Check that no keys are created or deleted in the key ranges observed by
+	// the transaction. Keys that are accessed directly are already checked
+	// above.
+	for _, r := range tx.ranges {
+		c := db.store.Cursor()
+		for ok := c.SeekGE(r.begin); ok && r.contains(c.Key()); ok = c.Next() {
+			key, mv := c.Key(), c.Value()
+			if _, ok := tx.accesses[key]; ok {
+				continue
 			}
-			if curval.Version != begval.Version {
-				return fmt.Errorf("precommit: %v key %q is updated by tx-%d after this tx-%d accessed version %d", tx, key, curval.Version, txval.Version, begval.Version)
+			curval, cok := mv.Fetch(math.MaxInt64)
+			begval, bok := mv.Fetch(tx.lastCommitVersion)
+			existed := bok && begval.Visible(tx.readTime)
+			exists := cok && curval.Visible(tx.readTime)
+			if existed != exists {
+				db.numConflicts.Add(1)
+				return fmt.Errorf("precommit: %v: phantom: %w", tx, newConflictError(tx, key, begval, curval))
 			}
 		}
 	}
 
+	// Check that keys in the ranges deleted by the transaction are not updated
+	// by newer commits, which would be lost otherwise. Range deletes are
+	// write operations, so they are checked for all isolation levels.
+	for _, r := range tx.deletes {
+		c := db.store.Cursor()
+		for ok := c.SeekGE(r.begin); ok && r.contains(c.Key()); ok = c.Next() {
+			key, mv := c.Key(), c.Value()
+			curval, cok := mv.Fetch(math.MaxInt64)
+			if !cok || curval.Version <= tx.lastCommitVersion {
+				continue
+			}
+			begval, _ := mv.Fetch(tx.lastCommitVersion)
+			db.numConflicts.Add(1)
+			return fmt.Errorf("precommit: %v: deleted range: %w", tx, newConflictError(tx, key, begval, curval))
+		}
+	}
+
 	newCommitVersion := db.maxCommitVersion + 1
 
+	// Merged keys are not in the read set, so they are resolved against the
+	// latest committed values.
+	writes, err := db.resolveMergesLocked(tx, newCommitVersion, time.Now().UnixNano())
+	if err != nil {
+		return fmt.Errorf("precommit: %v: %w", tx, err)
+	}
+	writes = append(writes, db.resolveDeletesLocked(tx, newCommitVersion, time.Now().UnixNano())...)
+
 	for key, value := range tx.accesses {
 		if value.Version == tx.version {
-			mv, ok := db.store.Load(key)
-			if !ok {
-				mv = new(multival.MultiValue)
-				if _, loaded := db.store.LoadOrStore(key, mv); loaded {
-					panic("unexpected: load-or-store failed")
-				}
-			}
-
 			value.Version = newCommitVersion
-			newmv := multival.Append(mv, value)
-			newmv = multival.Compact(newmv, minVersion)
-
-			if newmv.Empty() {
-				// log.Printf("commit %v key %s oldmv %v newmv nil", tx, key, mv)
-				if !db.store.CompareAndDelete(key, mv) {
-					panic("compare-and-delete")
-				}
-			} else {
-				// log.Printf("commit %v key %s oldmv %v newmv %v", tx, key, mv, newmv)
-				if !db.store.CompareAndSwap(key, mv, newmv) {
-					panic("compare-and-swap")
-				}
-			}
+			writes = append(writes, write{key: key, value: value})
 		}
 	}
 
-	db.maxCommitVersion = newCommitVersion
+	if db.wal != nil {
+		if err := db.wal.append(newCommitVersion, writes); err != nil {
+			return fmt.Errorf("could not write commit %d to the wal: %w", newCommitVersion, err)
+		}
+	}
+
+	db.applyLocked(newCommitVersion, minVersion, writes)
+	tx.commitVersion = newCommitVersion
 	return nil
 }
 
-// Compact removes unnecessary values from the database. Returns number of
-// keys compacted.
-func Compact(ctx context.Context, db *DB) int {
-	db.mu.Lock()
-	minVersion := db.maxCommitVersion
-	for k := range db.pins {
-		if k < minVersion {
-			minVersion = k
+// resolveDeletesLocked returns the deletions for the latest committed keys in
+// the ranges deleted by a transaction. Keys written or merged by the
+// transaction after the DeleteRange are skipped.
+//
+// Caller must hold the db.mu lock.
+func (db *DB) resolveDeletesLocked(tx *Transaction, version, now int64) []write {
+	var writes []write
+	seen := make(map[string]bool)
+	for _, r := range tx.deletes {
+		c := db.store.Cursor()
+		for ok := c.SeekGE(r.begin); ok && r.contains(c.Key()); ok = c.Next() {
+			key := c.Key()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if v, ok := tx.accesses[key]; ok && v.Version == tx.version {
+				continue
+			}
+			if _, ok := tx.merges[key]; ok {
+				continue
+			}
+			if v, ok := c.Value().Fetch(db.maxCommitVersion); !ok || !v.Visible(now) {
+				continue
+			}
+			writes = append(writes, write{key: key, value: &multival.Value{Version: version, Deleted: true}})
 		}
 	}
-	db.mu.Unlock()
-
-	tx, _ := db.NewTransaction(ctx)
-	defer tx.Rollback(ctx)
+	return writes
+}
 
-	count := 0
-	compact := func(key string, mval *multival.MultiValue) bool {
-		curval, ok := mval.Fetch(math.MaxInt64)
+// applyLocked adds the values written by a commit to the store and advances
+// the max commit version. All values must have the same commit version.
+//
+// Caller must hold the db.mu lock.
+func (db *DB) applyLocked(version, minVersion int64, writes []write) {
+	for _, w := range writes {
+		key, value := w.key, w.value
+		mv, ok := db.store.Load(key)
 		if !ok {
-			return true
+			mv = new(multival.MultiValue)
+			if _, loaded := db.store.LoadOrStore(key, mv); loaded {
+				panic("unexpected: load-or-store failed")
+			}
 		}
-		if curval.Deleted && curval.Version < minVersion {
-			if err := tx.Delete(ctx, key); err != nil {
-				return false
+
+		newmv := multival.Append(mv, value)
+		newmv = multival.Compact(newmv, minVersion)
+
+		if newmv.Empty() {
+			db.updateCountsLocked(key, mv, nil)
+			// log.Printf("commit key %s oldmv %v newmv nil", key, mv)
+			if !db.store.CompareAndDelete(key, mv) {
+				panic("compare-and-delete")
+			}
+		} else {
+			db.updateCountsLocked(key, mv, newmv)
+			// log.Printf("commit key %s oldmv %v newmv %v", key, mv, newmv)
+			if !db.store.CompareAndSwap(key, mv, newmv) {
+				panic("compare-and-swap")
 			}
-			count++
 		}
-		return true
 	}
-	db.store.Range(compact)
 
-	if err := tx.Commit(ctx); err != nil {
-		return -1
+	db.maxCommitVersion = version
+	db.recordChangesLocked(version, writes)
+	if db.opts.RetainDuration > 0 {
+		db.commitTimes = append(db.commitTimes, commitTime{version: version, timestamp: time.Now()})
+	}
+}
+
+// newConflictError returns a conflict error for a key that was observed as
+// begval by the transaction, but was changed to curval by a newer commit.
+func newConflictError(tx *Transaction, key string, begval, curval *multival.Value) *kv.ConflictError {
+	cerr := &kv.ConflictError{
+		Key:       key,
+		Reason:    kv.ConflictUpdated,
+		TxVersion: tx.version,
+	}
+	if begval != nil {
+		cerr.ReadVersion = begval.Version
+	}
+	if curval != nil {
+		cerr.CurrentVersion = curval.Version
+	}
+
+	existed := begval != nil && begval.Visible(tx.readTime)
+	exists := curval != nil && curval.Visible(tx.readTime)
+	if !existed && exists {
+		cerr.Reason = kv.ConflictCreated
+	}
+	if existed && !exists {
+		cerr.Reason = kv.ConflictDeleted
 	}
-	return count
+	return cerr
 }
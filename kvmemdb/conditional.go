@@ -0,0 +1,144 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kvmemdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/bvkgo/kv"
+	"github.com/bvkgo/kv/internal/multival"
+)
+
+// CompareAndSet updates the key to newValue if its current value is equal to
+// oldValue. Update is committed as a new version without a transaction.
+func (db *DB) CompareAndSet(ctx context.Context, key string, oldValue, newValue io.Reader) error {
+	old, err := io.ReadAll(oldValue)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(newValue)
+	if err != nil {
+		return err
+	}
+	check := func(cur []byte, _ int64, exists bool) error {
+		if !exists {
+			return fmt.Errorf("key %q doesn't exist: %w", key, kv.ErrConditionFailed)
+		}
+		if !bytes.Equal(cur, old) {
+			return fmt.Errorf("key %q has a different value: %w", key, kv.ErrConditionFailed)
+		}
+		return nil
+	}
+	return db.commitIf(key, check, &multival.Value{Data: data})
+}
+
+// CompareVersionAndSet updates the key to newValue if the commit version of
+// its current value is equal to the given version. Zero version matches only
+// the keys that do not exist. Deleted and expired keys do not exist.
+func (db *DB) CompareVersionAndSet(ctx context.Context, key string, version int64, newValue io.Reader) error {
+	data, err := io.ReadAll(newValue)
+	if err != nil {
+		return err
+	}
+	check := func(_ []byte, cur int64, exists bool) error {
+		if version == 0 && exists {
+			return fmt.Errorf("key %q already exists: %w", key, kv.ErrConditionFailed)
+		}
+		if version != 0 && !exists {
+			return fmt.Errorf("key %q doesn't exist: %w", key, kv.ErrConditionFailed)
+		}
+		if cur != version {
+			return fmt.Errorf("key %q has version %d, not %d: %w", key, cur, version, kv.ErrConditionFailed)
+		}
+		return nil
+	}
+	return db.commitIf(key, check, &multival.Value{Data: data})
+}
+
+// SetIfAbsent creates the key with the given value if it doesn't exist.
+// Expired keys are treated as absent.
+func (db *DB) SetIfAbsent(ctx context.Context, key string, value io.Reader) error {
+	data, err := io.ReadAll(value)
+	if err != nil {
+		return err
+	}
+	check := func(cur []byte, _ int64, exists bool) error {
+		if exists {
+			return fmt.Errorf("key %q already exists: %w", key, kv.ErrConditionFailed)
+		}
+		return nil
+	}
+	return db.commitIf(key, check, &multival.Value{Data: data})
+}
+
+// DeleteIfEqual deletes the key if its current value is equal to the given
+// value.
+func (db *DB) DeleteIfEqual(ctx context.Context, key string, value io.Reader) error {
+	want, err := io.ReadAll(value)
+	if err != nil {
+		return err
+	}
+	check := func(cur []byte, _ int64, exists bool) error {
+		if !exists {
+			return fmt.Errorf("key %q doesn't exist: %w", key, kv.ErrConditionFailed)
+		}
+		if !bytes.Equal(cur, want) {
+			return fmt.Errorf("key %q has a different value: %w", key, kv.ErrConditionFailed)
+		}
+		return nil
+	}
+	return db.commitIf(key, check, &multival.Value{Deleted: true})
+}
+
+// commitIf commits a new value for a single key if the check function accepts
+// the latest committed value of the key.
+func (db *DB) commitIf(key string, check func(data []byte, version int64, exists bool) error, value *multival.Value) error {
+	if len(key) == 0 {
+		return os.ErrInvalid
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return os.ErrClosed
+	}
+
+	var cur *multival.Value
+	if mv, ok := db.store.Load(key); ok {
+		cur, _ = mv.Fetch(db.maxCommitVersion)
+	}
+	exists := cur != nil && cur.Visible(time.Now().UnixNano())
+
+	var data []byte
+	var version int64
+	if exists {
+		data, version = cur.Data, cur.Version
+	}
+	if err := check(data, version, exists); err != nil {
+		return err
+	}
+
+	minVersion := db.minVersionLocked()
+	value.Version = db.maxCommitVersion + 1
+
+	// Transactions identify their own writes by the transaction version, so
+	// the new transactions must not reuse this commit version.
+	db.lastTxVersion = max(db.lastTxVersion, value.Version)
+
+	writes := []write{{key: key, value: value}}
+	if db.wal != nil {
+		if err := db.wal.append(value.Version, writes); err != nil {
+			return fmt.Errorf("could not write commit %d to the wal: %w", value.Version, err)
+		}
+	}
+
+	db.applyLocked(value.Version, minVersion, writes)
+	db.numCommits.Add(1)
+	return nil
+}
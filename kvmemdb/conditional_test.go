@@ -0,0 +1,213 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bvkgo/kv"
+)
+
+func TestConditionalWrites(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := New()
+	value := func() (string, error) {
+		snap, err := db.NewSnapshot(ctx)
+		if err != nil {
+			return "", err
+		}
+		defer snap.Discard(ctx)
+
+		r, err := snap.Get(ctx, "key")
+		if err != nil {
+			return "", err
+		}
+		data, err := io.ReadAll(r)
+		return string(data), err
+	}
+
+	if err := db.CompareAndSet(ctx, "key", strings.NewReader(""), strings.NewReader("one")); !errors.Is(err, kv.ErrConditionFailed) {
+		t.Fatalf("want ErrConditionFailed for a missing key, got %v", err)
+	}
+	if err := db.SetIfAbsent(ctx, "key", strings.NewReader("one")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetIfAbsent(ctx, "key", strings.NewReader("two")); !errors.Is(err, kv.ErrConditionFailed) {
+		t.Fatalf("want ErrConditionFailed, got %v", err)
+	}
+	if err := db.CompareAndSet(ctx, "key", strings.NewReader("two"), strings.NewReader("three")); !errors.Is(err, kv.ErrConditionFailed) {
+		t.Fatalf("want ErrConditionFailed, got %v", err)
+	}
+	if err := db.CompareAndSet(ctx, "key", strings.NewReader("one"), strings.NewReader("two")); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := value(); err != nil || v != "two" {
+		t.Fatalf("want two, got %q, %v", v, err)
+	}
+
+	// Transactions that read the key conflict with the conditional writes.
+	tx, err := db.NewTransaction(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Get(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteIfEqual(ctx, "key", strings.NewReader("one")); !errors.Is(err, kv.ErrConditionFailed) {
+		t.Fatalf("want ErrConditionFailed, got %v", err)
+	}
+	if err := db.DeleteIfEqual(ctx, "key", strings.NewReader("two")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(ctx); !errors.Is(err, kv.ErrConflict) {
+		t.Fatalf("want ErrConflict, got %v", err)
+	}
+	if _, err := value(); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("want ErrNotExist, got %v", err)
+	}
+
+	if s := db.Stats(); s.MaxCommitVersion != 3 {
+		t.Fatalf("want 3 commits, got %d", s.MaxCommitVersion)
+	}
+}
+
+func TestConditionalWritesWithTransactions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := New()
+	for _, k := range []string{"a", "b", "c"} {
+		if err := db.SetIfAbsent(ctx, k, strings.NewReader(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Transaction versions must not collide with the conditional commit
+	// versions, otherwise committed values are treated as local writes.
+	var txs []kv.Transaction
+	for i := 0; i < 3; i++ {
+		tx, err := db.NewTransaction(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txs = append(txs, tx)
+	}
+	for _, tx := range txs[:2] {
+		if err := tx.Rollback(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tx := txs[2]
+	if _, err := tx.Get(ctx, "c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Set(ctx, "c", strings.NewReader("dirty")); err != nil {
+		t.Fatal(err)
+	}
+
+	value := func() string {
+		snap, err := db.NewSnapshot(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer snap.Discard(ctx)
+
+		r, err := snap.Get(ctx, "c")
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	if v := value(); v != "c" {
+		t.Fatalf("want c before commit, got %q", v)
+	}
+	if err := tx.Rollback(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if v := value(); v != "c" {
+		t.Fatalf("want c after rollback, got %q", v)
+	}
+
+	// Transactions and conditional writes share the commit versions.
+	if err := kv.WithReadWriter(ctx, db, func(ctx context.Context, rw kv.ReadWriter) error {
+		return rw.Set(ctx, "d", strings.NewReader("d"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CompareAndSet(ctx, "d", strings.NewReader("d"), strings.NewReader("e")); err != nil {
+		t.Fatal(err)
+	}
+	if s := db.Stats(); s.MaxCommitVersion != 5 {
+		t.Fatalf("want 5 commits, got %d", s.MaxCommitVersion)
+	}
+}
+
+func TestCompareVersionAndSet(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := New()
+	version := func() int64 {
+		snap, err := db.NewSnapshot(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer snap.Discard(ctx)
+
+		it, err := snap.(kv.Historian).History(ctx, "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer it.Close()
+
+		var last int64
+		for c, err := it.Next(ctx); err == nil; c, err = it.Next(ctx) {
+			last = c.Version
+		}
+		return last
+	}
+
+	if err := db.CompareVersionAndSet(ctx, "key", 1, strings.NewReader("one")); !errors.Is(err, kv.ErrConditionFailed) {
+		t.Fatalf("want ErrConditionFailed for a missing key, got %v", err)
+	}
+	if err := db.CompareVersionAndSet(ctx, "key", 0, strings.NewReader("one")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CompareVersionAndSet(ctx, "key", 0, strings.NewReader("two")); !errors.Is(err, kv.ErrConditionFailed) {
+		t.Fatalf("want ErrConditionFailed for an existing key, got %v", err)
+	}
+
+	v := version()
+	if err := db.CompareVersionAndSet(ctx, "key", v+1, strings.NewReader("two")); !errors.Is(err, kv.ErrConditionFailed) {
+		t.Fatalf("want ErrConditionFailed for a different version, got %v", err)
+	}
+	if err := db.CompareVersionAndSet(ctx, "key", v, strings.NewReader("two")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CompareVersionAndSet(ctx, "key", v, strings.NewReader("three")); !errors.Is(err, kv.ErrConditionFailed) {
+		t.Fatalf("want ErrConditionFailed for an older version, got %v", err)
+	}
+
+	// Deleted keys match only the zero version.
+	if err := db.DeleteIfEqual(ctx, "key", strings.NewReader("two")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CompareVersionAndSet(ctx, "key", version(), strings.NewReader("three")); !errors.Is(err, kv.ErrConditionFailed) {
+		t.Fatalf("want ErrConditionFailed for a deleted key, got %v", err)
+	}
+	if err := db.CompareVersionAndSet(ctx, "key", 0, strings.NewReader("three")); err != nil {
+		t.Fatal(err)
+	}
+}
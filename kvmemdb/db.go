@@ -3,52 +3,194 @@
 package kvmemdb
 
 import (
+	"container/list"
 	"context"
+	"fmt"
+	"math"
+	"os"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/bvkgo/kv"
 	"github.com/bvkgo/kv/internal/multival"
-	"github.com/bvkgo/kv/internal/syncmap"
+	"github.com/bvkgo/kv/internal/ordmap"
 )
 
+// Options holds optional configuration parameters for the database.
+type Options struct {
+	// RetainVersions when positive keeps older data for the given number of
+	// most recent commit versions, so that snapshots can be opened at those
+	// versions with NewSnapshotAt.
+	RetainVersions int
+
+	// RetainDuration when positive keeps older data for all commit versions
+	// committed in the given duration, so that snapshots can be opened at
+	// those versions with NewSnapshotAt.
+	RetainDuration time.Duration
+
+	// Isolation selects the isolation level for the transactions. Default is
+	// kv.Serializable.
+	Isolation kv.IsolationLevel
+
+	// WALSync selects when the write-ahead log is synced to the disk for the
+	// databases created with Open. Default is SyncAlways.
+	WALSync SyncPolicy
+
+	// WALSyncInterval is the sync interval for the SyncInterval policy. Default
+	// is 100ms.
+	WALSyncInterval time.Duration
+
+	// CheckpointInterval is the interval between automatic checkpoints for the
+	// databases created with Open. Default is 10 minutes. Negative value
+	// disables the automatic checkpoints.
+	CheckpointInterval time.Duration
+
+	// Debug when true records the creation time and the goroutine stack for
+	// all snapshots and transactions, which are reported by OpenHandles and
+	// CheckLeaks methods.
+	Debug bool
+
+	// MaxHandleAge when positive releases the snapshots and transactions that
+	// are open for longer than the given duration, so that they don't block
+	// the removal of older versions forever. Released snapshots and
+	// transactions return ErrExpired on their next use.
+	MaxHandleAge time.Duration
+
+	// WatchHistory is the number of most recent commits retained for the
+	// watchers, so that slow watchers and watchers resuming from an older
+	// version can catch up. Default is 1000. Negative value disables the
+	// retention, so watchers receive only the commits made while at least one
+	// watcher is open and cannot resume after all watchers are closed.
+	WatchHistory int
+}
+
+func (opts *Options) setDefaults() {
+	if opts.WALSyncInterval <= 0 {
+		opts.WALSyncInterval = 100 * time.Millisecond
+	}
+	if opts.WatchHistory == 0 {
+		opts.WatchHistory = defaultWatchHistory
+	}
+	if opts.CheckpointInterval == 0 {
+		opts.CheckpointInterval = 10 * time.Minute
+	}
+}
+
 type DB struct {
 	mu sync.Mutex
 
+	opts Options
+
+	// closed is set to true when the database is closed.
+	closed bool
+
+	// wal is non-nil for databases created with Open. All commits are saved in
+	// the write-ahead log before they are applied to the store.
+	wal *wal
+
+	// dir is the database directory for databases created with Open.
+	dir string
+
+	// checkpointMu serializes the checkpoint operations. checkpointVersion
+	// holds the commit version saved by the last checkpoint.
+	checkpointMu      sync.Mutex
+	checkpointVersion int64
+
+	// gcTotals holds the cumulative stats from all garbage collection passes.
+	// compacting is true when the background compactor is running.
+	gcTotals   GCStats
+	compacting bool
+
+	// done is closed to stop the background goroutines and wg waits for them
+	// to finish.
+	done chan struct{}
+	wg   sync.WaitGroup
+
 	// pins holds a commit version and total number of snapshot and transaction
 	// references to it.
 	pins map[int64]int
 
+	// pinTimes holds the creation time of the oldest snapshot or transaction
+	// for the pinned versions. It may be older than the oldest live reference,
+	// which only delays the removal of expired keys.
+	pinTimes map[int64]int64
+
+	// handles holds the open snapshots and transactions in their creation
+	// order. It is used only when Debug or MaxHandleAge options are
+	// configured.
+	handles *list.List
+
 	// maxCommitVersion holds the last committed transaction version.
 	maxCommitVersion int64
 
 	// lastTxVersion holds the most recent tx version.
 	lastTxVersion int64
 
+	// horizon holds the smallest commit version that can be read. Data for the
+	// older commit versions may have been removed by the compaction.
+	horizon int64
+
+	// commitTimes holds the commit timestamps for recent commits in the
+	// increasing order of the commit versions. It is used only when
+	// RetainDuration option is configured.
+	commitTimes []commitTime
+
 	// store holds the key-value data for multiple committed versions. Each value
 	// can hold data for multiple versions cause snapshots may need access to
 	// older data, while newer transactions have updated the DB values. Note that
-	// store never has dirty (uncommitted) values.
-	store syncmap.Map[string, *multival.MultiValue]
+	// store never has dirty (uncommitted) values. Keys in the store are ordered,
+	// so that range iterations do not need to sort the keys.
+	store ordmap.Map[*multival.MultiValue]
+
+	// changes holds the recent commits for the watchers in the increasing order
+	// of commit versions. All commits from changesFrom version are present in
+	// the changes. Watchers wait on the changed channel, which is closed on
+	// every commit. When the WatchHistory option is negative, changes are
+	// recorded only when numWatchers is positive.
+	changes     []commitChanges
+	changesFrom int64
+	changed     chan struct{}
+	numWatchers int
+
+	// mergeFuncs holds the merge functions registered for the key prefixes.
+	mergeFuncs map[string]MergeFunc
+
+	// numKeys, numVersions and numBytes hold the number of keys, number of
+	// versions and total size of the values in the store.
+	numKeys, numVersions, numBytes int64
+
+	// expiring holds the expiry time for the keys whose latest value has a
+	// TTL, so that the expired keys are not counted in the stats.
+	expiring map[string]int64
+
+	// numCommits, numRollbacks and numConflicts count the transactions by their
+	// outcome.
+	numCommits, numRollbacks, numConflicts atomic.Int64
+}
+
+type commitTime struct {
+	version   int64
+	timestamp time.Time
 }
 
 func New() *DB {
-	return &DB{
-		pins: make(map[int64]int),
-	}
+	return NewWithOptions(nil)
 }
 
-func (db *DB) keys(skip map[string]*multival.Value) []string {
-	var keys []string
-	db.store.Range(func(key string, _ *multival.MultiValue) bool {
-		if skip != nil {
-			if _, ok := skip[key]; ok {
-				return true
-			}
-		}
-		keys = append(keys, key)
-		return true
-	})
-	return keys
+// NewWithOptions creates an empty database with the given options. Nil
+// options are same as the default options.
+func NewWithOptions(opts *Options) *DB {
+	db := &DB{
+		pins:     make(map[int64]int),
+		pinTimes: make(map[int64]int64),
+		done:     make(chan struct{}),
+	}
+	if opts != nil {
+		db.opts = *opts
+	}
+	db.opts.setDefaults()
+	return db
 }
 
 func (db *DB) NewSnapshot(ctx context.Context) (kv.Snapshot, error) {
@@ -58,13 +200,69 @@ func (db *DB) NewSnapshot(ctx context.Context) (kv.Snapshot, error) {
 	s := &Snapshot{
 		db:                db,
 		lastCommitVersion: db.maxCommitVersion,
+		readTime:          time.Now().UnixNano(),
+		handle:            db.newHandleLocked("snapshot", db.maxCommitVersion),
 	}
 
-	db.pins[db.maxCommitVersion]++
+	db.pinLocked(db.maxCommitVersion, s.readTime)
+	return s, nil
+}
+
+// NewSnapshotAt returns a snapshot of the database at an older commit
+// version. Database must be configured with a retention option to keep the
+// older versions around.
+func (db *DB) NewSnapshotAt(ctx context.Context, version int64) (kv.Snapshot, error) {
+	return db.newSnapshotAt(version, true /* track */)
+}
+
+// newSnapshotAt creates a snapshot at an older version. Internal snapshots
+// that are not tracked never expire.
+func (db *DB) newSnapshotAt(version int64, track bool) (*Snapshot, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if version > db.maxCommitVersion {
+		return nil, fmt.Errorf("version %d is not committed yet: %w", version, os.ErrInvalid)
+	}
+	if version < db.horizon {
+		return nil, fmt.Errorf("version %d is older than the retained version %d: %w", version, db.horizon, os.ErrNotExist)
+	}
+
+	s := &Snapshot{
+		db:                db,
+		lastCommitVersion: version,
+		readTime:          time.Now().UnixNano(),
+	}
+	if track {
+		s.handle = db.newHandleLocked("snapshot", version)
+	}
+
+	db.pinLocked(version, s.readTime)
 	return s, nil
 }
 
 func (db *DB) NewTransaction(ctx context.Context) (kv.Transaction, error) {
+	return db.NewTransactionWithOptions(ctx, nil)
+}
+
+// NewTransactionWithOptions creates a transaction as per the input options.
+// Transaction isolation level, when not specified, is taken from the database
+// options.
+func (db *DB) NewTransactionWithOptions(ctx context.Context, opts *kv.TransactionOptions) (kv.Transaction, error) {
+	var o kv.TransactionOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Isolation == kv.DefaultIsolation {
+		o.Isolation = db.opts.Isolation
+	}
+	if o.Isolation == kv.DefaultIsolation {
+		o.Isolation = kv.Serializable
+	}
+	if o.Isolation != kv.SnapshotIsolation && o.Isolation != kv.Serializable {
+		return nil, fmt.Errorf("unsupported isolation level %v: %w", o.Isolation, os.ErrInvalid)
+	}
+
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
@@ -74,25 +272,112 @@ func (db *DB) NewTransaction(ctx context.Context) (kv.Transaction, error) {
 	t := &Transaction{
 		db:                db,
 		lastCommitVersion: db.maxCommitVersion,
+		readTime:          time.Now().UnixNano(),
 		version:           version,
-		accesses:          make(map[string]*multival.Value),
+		readOnly:          o.ReadOnly,
+		isolation:         o.Isolation,
+		handle:            db.newHandleLocked("transaction", db.maxCommitVersion),
+	}
+	if !t.readOnly {
+		t.accesses = make(map[string]*multival.Value)
 	}
 
-	db.pins[db.maxCommitVersion]++
+	db.pinLocked(db.maxCommitVersion, t.readTime)
 	return t, nil
 }
 
-func (db *DB) releasePin(version int64) {
+func (db *DB) releasePin(version int64, h *handle) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
+	if h != nil {
+		if h.expired.Load() {
+			return ErrExpired // Pin is already released.
+		}
+		db.handles.Remove(h.elem)
+	}
+
 	// Release the pin on the committed version, so that it can be discarded
 	// later.
+	db.unpinLocked(version)
+	return nil
+}
+
+// pinLocked adds a reference to the commit version from a snapshot or
+// transaction created at the given time.
+//
+// Caller must hold the db.mu lock.
+func (db *DB) pinLocked(version, readTime int64) {
+	db.pins[version]++
+	if t, ok := db.pinTimes[version]; !ok || readTime < t {
+		db.pinTimes[version] = readTime
+	}
+}
 
+func (db *DB) unpinLocked(version int64) {
 	n := db.pins[version]
 	if n == 1 {
 		delete(db.pins, version)
+		delete(db.pinTimes, version)
 	} else {
 		db.pins[version] = n - 1
 	}
 }
+
+// minVersionLocked returns the smallest commit version that must remain
+// readable. Data older than the returned version can be discarded. It also
+// advances the horizon, so caller must discard only the data older than the
+// returned version.
+//
+// Snapshots and transactions that are older than the MaxHandleAge option are
+// released first.
+//
+// Caller must hold the db.mu lock.
+func (db *DB) minVersionLocked() int64 {
+	db.expireHandlesLocked()
+
+	minVersion := db.maxCommitVersion
+	for k := range db.pins {
+		if k < minVersion {
+			minVersion = k
+		}
+	}
+	if v := db.retainedVersionLocked(); v < minVersion {
+		minVersion = v
+	}
+
+	if minVersion > db.horizon {
+		db.horizon = minVersion
+	}
+	return db.horizon
+}
+
+// retainedVersionLocked returns the smallest commit version that must be kept
+// as per the retention options. Returns math.MaxInt64 if no retention options
+// are configured.
+//
+// Caller must hold the db.mu lock.
+func (db *DB) retainedVersionLocked() int64 {
+	retained := int64(math.MaxInt64)
+	if n := int64(db.opts.RetainVersions); n > 0 {
+		retained = max(db.maxCommitVersion-n+1, 0)
+	}
+	if d := db.opts.RetainDuration; d > 0 {
+		// Drop the commit timestamps older than the retention window, but keep
+		// the last one of them, so that data as it was at the beginning of the
+		// window is retained.
+		limit := time.Now().Add(-d)
+		n := 0
+		for n+1 < len(db.commitTimes) && db.commitTimes[n+1].timestamp.Before(limit) {
+			n++
+		}
+		db.commitTimes = db.commitTimes[n:]
+
+		v := db.maxCommitVersion
+		if len(db.commitTimes) > 0 {
+			v = db.commitTimes[0].version
+		}
+		retained = min(retained, v)
+	}
+	return retained
+}
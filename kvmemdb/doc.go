@@ -5,6 +5,10 @@ Package kvmemdb implements an in-memory key-value database with snapshots and
 read/write transactions. Snapshots can be taken periodically to backup and
 restore the database.
 
+Databases created with Open are durable. Every commit is saved in a
+write-ahead log in the database directory before it is applied, and the
+database content is checkpointed to the same directory periodically.
+
 Database can be used by multiple goroutines simultaneously, however, individual
 Snapshot and Transaction objects are not thread-safe; they can only be used by
 a single goroutine.
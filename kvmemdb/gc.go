@@ -0,0 +1,187 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kvmemdb
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"time"
+
+	"github.com/bvkgo/kv/internal/multival"
+)
+
+// gcBatchSize is the number of keys processed by the garbage collector with
+// the database lock held.
+const gcBatchSize = 1024
+
+// GCStats holds the number of items reclaimed by the garbage collection.
+type GCStats struct {
+	// Keys is the number of deleted keys removed from the database.
+	Keys int64
+
+	// Versions is the number of older versions removed from the database.
+	Versions int64
+
+	// Bytes is the total size of the values removed from the database.
+	Bytes int64
+}
+
+func (s *GCStats) add(other *GCStats) {
+	s.Keys += other.Keys
+	s.Versions += other.Versions
+	s.Bytes += other.Bytes
+}
+
+// GC removes the older versions of all keys that are not visible to any of
+// the live snapshots and transactions or retained by the version retention
+// options. Deleted keys are removed completely when their deletion is visible
+// to all snapshots and transactions. Similarly, expired keys are removed when
+// they have expired for all snapshots and transactions.
+//
+// Keys are processed in small batches, so that commits are not blocked for a
+// long time.
+func (db *DB) GC(ctx context.Context) (*GCStats, error) {
+	stats := new(GCStats)
+	defer func() {
+		db.mu.Lock()
+		db.gcTotals.add(stats)
+		db.mu.Unlock()
+	}()
+
+	var next string
+	for first := true; first || next != ""; first = false {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		next = db.gcBatch(next, stats)
+	}
+	return stats, nil
+}
+
+// gcBatch compacts a batch of keys starting from the input key. Returns the
+// key for the next batch or empty string if all keys are processed.
+func (db *DB) gcBatch(begin string, stats *GCStats) string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	minVersion := db.minVersionLocked()
+
+	// Expired values can be removed only if they are expired for all live
+	// snapshots and transactions.
+	expiryLimit := time.Now().UnixNano()
+	for _, t := range db.pinTimes {
+		expiryLimit = min(expiryLimit, t)
+	}
+
+	c := db.store.Cursor()
+	ok := c.SeekGE(begin)
+	for i := 0; ok && i < gcBatchSize; i, ok = i+1, c.Next() {
+		key, mv := c.Key(), c.Value()
+
+		newmv := multival.Compact(mv, minVersion)
+		if newmv.Empty() || isExpired(newmv, minVersion, expiryLimit) {
+			if !db.store.CompareAndDelete(key, mv) {
+				panic("compare-and-delete")
+			}
+			db.updateCountsLocked(key, mv, nil)
+			stats.Keys++
+			stats.Versions += int64(mv.Len())
+			stats.Bytes += mv.DataSize()
+			continue
+		}
+		if newmv != mv {
+			if !db.store.CompareAndSwap(key, mv, newmv) {
+				panic("compare-and-swap")
+			}
+			db.updateCountsLocked(key, mv, newmv)
+			stats.Versions += int64(mv.Len() - newmv.Len())
+			stats.Bytes += mv.DataSize() - newmv.DataSize()
+		}
+	}
+	if !ok {
+		return ""
+	}
+	return c.Key()
+}
+
+// isExpired returns true if the latest value in the multi-value has expired
+// before the expiry limit and no older versions are necessary.
+func isExpired(mv *multival.MultiValue, minVersion, expiryLimit int64) bool {
+	v, ok := mv.Fetch(math.MaxInt64)
+	if !ok || v.Deleted || v.ExpiresAt == 0 {
+		return false
+	}
+	return v.Version <= minVersion && v.ExpiresAt <= expiryLimit
+}
+
+// GCTotals returns the cumulative stats from all garbage collection passes,
+// including the passes by the background compactor.
+func (db *DB) GCTotals() GCStats {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.gcTotals
+}
+
+// StartCompactor starts a background goroutine that runs the garbage
+// collection periodically with the given interval. Compactor is stopped when
+// the database is closed.
+func (db *DB) StartCompactor(interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("compactor interval must be positive: %w", os.ErrInvalid)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return os.ErrClosed
+	}
+	if db.compacting {
+		return fmt.Errorf("compactor is already running: %w", os.ErrExist)
+	}
+	db.compacting = true
+
+	db.wg.Add(1)
+	go db.goCompactor(interval)
+	return nil
+}
+
+func (db *DB) goCompactor(interval time.Duration) {
+	defer db.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		<-db.done
+		cancel()
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-db.done:
+			return
+		case <-ticker.C:
+			if _, err := db.GC(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("kvmemdb: could not complete the garbage collection: %v", err)
+			}
+		}
+	}
+}
+
+// Compact removes unnecessary values from the database. Returns number of
+// keys removed or -1 on errors.
+func Compact(ctx context.Context, db *DB) int {
+	stats, err := db.GC(ctx)
+	if err != nil {
+		return -1
+	}
+	return int(stats.Keys)
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bvkgo/kv"
+)
+
+func TestGC(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := New()
+	set := func(key, value string) {
+		f := func(ctx context.Context, rw kv.ReadWriter) error {
+			if value == "" {
+				return rw.Delete(ctx, key)
+			}
+			return rw.Set(ctx, key, strings.NewReader(value))
+		}
+		if err := kv.WithReadWriter(ctx, db, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	set("a", "1")
+	set("b", "1")
+	snap, err := db.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set("a", "22")
+	set("b", "")
+
+	// Older versions are necessary for the snapshot.
+	stats, err := db.GC(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *stats != (GCStats{}) {
+		t.Fatalf("want no garbage with a live snapshot, got %+v", stats)
+	}
+	if r, err := snap.Get(ctx, "b"); err != nil {
+		t.Fatal(err)
+	} else if v, _ := io.ReadAll(r); string(v) != "1" {
+		t.Fatalf("want 1, got %q", v)
+	}
+
+	snap.Discard(ctx)
+	stats, err = db.GC(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (GCStats{Keys: 1, Versions: 3, Bytes: 2}); *stats != want {
+		t.Fatalf("want %+v, got %+v", want, *stats)
+	}
+	if mv, ok := db.store.Load("a"); !ok || mv.Len() != 1 {
+		t.Fatalf("want a single version for key a, got %v", mv)
+	}
+	if _, ok := db.store.Load("b"); ok {
+		t.Fatalf("want deleted key b to be removed")
+	}
+	if totals := db.GCTotals(); totals != *stats {
+		t.Fatalf("want totals %+v, got %+v", *stats, totals)
+	}
+}
+
+func TestCompactor(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := New()
+	if err := db.StartCompactor(time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.StartCompactor(time.Millisecond); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("want ErrExist, got %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		snap, err := db.NewSnapshot(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		set := func(ctx context.Context, rw kv.ReadWriter) error {
+			return rw.Set(ctx, "key", strings.NewReader("value"))
+		}
+		if err := kv.WithReadWriter(ctx, db, set); err != nil {
+			t.Fatal(err)
+		}
+		snap.Discard(ctx)
+	}
+
+	for db.GCTotals().Versions == 0 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("compactor didn't reclaim any versions")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.StartCompactor(time.Millisecond); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("want ErrClosed, got %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kvmemdb
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ErrExpired is returned by the snapshots and transactions that are released
+// forcibly because they were open for longer than the Options.MaxHandleAge.
+var ErrExpired = errors.New("snapshot or transaction has expired")
+
+// HandleInfo describes an open snapshot or transaction.
+type HandleInfo struct {
+	// Kind is either "snapshot" or "transaction".
+	Kind string
+
+	// Version is the commit version pinned by the handle.
+	Version int64
+
+	// Created is the handle creation time.
+	Created time.Time
+
+	// Stack holds the goroutine stack that created the handle. It is recorded
+	// only when Options.Debug is true.
+	Stack string
+}
+
+// handle tracks an open snapshot or transaction when the handle tracking is
+// enabled.
+type handle struct {
+	info HandleInfo
+
+	// elem is the position of the handle in the db.handles list.
+	elem *list.Element
+
+	// expired is set when the handle is released forcibly.
+	expired atomic.Bool
+}
+
+// check returns ErrExpired if the handle has expired. It is safe to call on
+// nil handles.
+func (h *handle) check() error {
+	if h != nil && h.expired.Load() {
+		return ErrExpired
+	}
+	return nil
+}
+
+// newHandleLocked registers a new handle for the pinned version. Returns nil
+// if the handle tracking is not enabled.
+//
+// Caller must hold the db.mu lock.
+func (db *DB) newHandleLocked(kind string, version int64) *handle {
+	if !db.opts.Debug && db.opts.MaxHandleAge <= 0 {
+		return nil
+	}
+	h := &handle{
+		info: HandleInfo{
+			Kind:    kind,
+			Version: version,
+			Created: time.Now(),
+		},
+	}
+	if db.opts.Debug {
+		h.info.Stack = string(debug.Stack())
+	}
+	if db.handles == nil {
+		db.handles = list.New()
+	}
+	h.elem = db.handles.PushBack(h)
+	return h
+}
+
+// expireHandlesLocked releases the pins held by the handles that are older
+// than the maximum handle age.
+//
+// Caller must hold the db.mu lock.
+func (db *DB) expireHandlesLocked() {
+	if db.opts.MaxHandleAge <= 0 || db.handles == nil {
+		return
+	}
+	limit := time.Now().Add(-db.opts.MaxHandleAge)
+	// Handles are in the order of their creation time.
+	for e := db.handles.Front(); e != nil; e = db.handles.Front() {
+		h := e.Value.(*handle)
+		if !h.info.Created.Before(limit) {
+			break
+		}
+		h.expired.Store(true)
+		db.handles.Remove(e)
+		db.unpinLocked(h.info.Version)
+	}
+}
+
+// OpenHandles returns the snapshots and transactions that are not released
+// yet, oldest first. Returns nil if neither Options.Debug nor
+// Options.MaxHandleAge is configured.
+func (db *DB) OpenHandles() []*HandleInfo {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.expireHandlesLocked()
+	if db.handles == nil {
+		return nil
+	}
+
+	var infos []*HandleInfo
+	for e := db.handles.Front(); e != nil; e = e.Next() {
+		info := e.Value.(*handle).info
+		infos = append(infos, &info)
+	}
+	return infos
+}
+
+// CheckLeaks returns a non-nil error if any snapshots or transactions are
+// not released yet. Error includes the creation stacks of the open handles
+// when Options.Debug is true. It is typically used at the end of the tests.
+func (db *DB) CheckLeaks() error {
+	if infos := db.OpenHandles(); len(infos) > 0 {
+		var sb strings.Builder
+		for _, info := range infos {
+			fmt.Fprintf(&sb, "\n%s at version %d created at %s", info.Kind, info.Version, info.Created.Format(time.RFC3339Nano))
+			if info.Stack != "" {
+				fmt.Fprintf(&sb, ":\n%s", info.Stack)
+			}
+		}
+		return fmt.Errorf("%d snapshots or transactions are not released:%s", len(infos), sb.String())
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	npins := 0
+	for _, n := range db.pins {
+		npins += n
+	}
+	if npins > 0 {
+		return fmt.Errorf("%d snapshots or transactions are not released", npins)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bvkgo/kv"
+)
+
+func TestCheckLeaks(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := NewWithOptions(&Options{Debug: true})
+	snap, err := db.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.NewTransaction(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	infos := db.OpenHandles()
+	if len(infos) != 2 || infos[0].Kind != "snapshot" || infos[1].Kind != "transaction" {
+		t.Fatalf("unexpected open handles %v", infos)
+	}
+	if err := db.CheckLeaks(); err == nil {
+		t.Fatalf("want leaks to be reported")
+	} else if !strings.Contains(err.Error(), "TestCheckLeaks") {
+		t.Fatalf("want creation stack in the leak report, got %v", err)
+	}
+
+	snap.Discard(ctx)
+	tx.Rollback(ctx)
+	if err := db.CheckLeaks(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Leaks are reported without the debug option too.
+	db = New()
+	tx, err = db.NewTransaction(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if infos := db.OpenHandles(); infos != nil {
+		t.Fatalf("want no handle tracking, got %v", infos)
+	}
+	if err := db.CheckLeaks(); err == nil {
+		t.Fatalf("want leaks to be reported")
+	}
+	tx.Rollback(ctx)
+	if err := db.CheckLeaks(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMaxHandleAge(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := NewWithOptions(&Options{MaxHandleAge: 10 * time.Millisecond})
+	set := func(ctx context.Context, rw kv.ReadWriter) error {
+		return rw.Set(ctx, "key", strings.NewReader("value"))
+	}
+	if err := kv.WithReadWriter(ctx, db, set); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := db.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	it, err := snap.Scan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.NewTransaction(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Set(ctx, "other", strings.NewReader("value")); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	// Expired handles are released by the next commit.
+	if err := kv.WithReadWriter(ctx, db, set); err != nil {
+		t.Fatal(err)
+	}
+	if s := db.Stats(); s.Pins != 0 {
+		t.Fatalf("want no pins after the expiry, got %d", s.Pins)
+	}
+
+	if _, err := snap.Get(ctx, "key"); !errors.Is(err, ErrExpired) {
+		t.Fatalf("want ErrExpired, got %v", err)
+	}
+	if _, _, err := it.Fetch(ctx, false); !errors.Is(err, ErrExpired) {
+		t.Fatalf("want ErrExpired from the iterator, got %v", err)
+	}
+	if err := snap.Discard(ctx); !errors.Is(err, ErrExpired) {
+		t.Fatalf("want ErrExpired, got %v", err)
+	}
+	if err := tx.Commit(ctx); !errors.Is(err, ErrExpired) {
+		t.Fatalf("want ErrExpired, got %v", err)
+	}
+	if err := db.CheckLeaks(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExpiryDuringReads(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := NewWithOptions(&Options{MaxHandleAge: time.Millisecond})
+	update := func(del bool) {
+		f := func(ctx context.Context, rw kv.ReadWriter) error {
+			if del {
+				return rw.Delete(ctx, "key")
+			}
+			return rw.Set(ctx, "key", strings.NewReader("value"))
+		}
+		if err := kv.WithReadWriter(ctx, db, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Reads that race with the expiry and the compaction of a deleted key must
+	// fail with ErrExpired, never with ErrNotExist.
+	for i := 0; i < 50; i++ {
+		update(false)
+		snap, err := db.NewSnapshot(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		errc := make(chan error, 1)
+		go func() {
+			for {
+				if _, err := snap.Get(ctx, "key"); err != nil {
+					errc <- err
+					return
+				}
+				it, err := snap.Scan(ctx)
+				if err != nil {
+					errc <- err
+					return
+				}
+				if _, _, err := it.Fetch(ctx, false); err != nil {
+					errc <- err
+					return
+				}
+			}
+		}()
+
+		update(true)
+		time.Sleep(2 * time.Millisecond)
+		if _, err := db.GC(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-errc; !errors.Is(err, ErrExpired) {
+			t.Fatalf("iteration %d: want ErrExpired, got %v", i, err)
+		}
+	}
+}
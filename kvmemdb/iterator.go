@@ -4,51 +4,161 @@ package kvmemdb
 
 import (
 	"context"
+	"errors"
 	"io"
+	"os"
+	"slices"
+
+	"github.com/bvkgo/kv"
+	"github.com/bvkgo/kv/internal/multival"
+	"github.com/bvkgo/kv/internal/ordmap"
 )
 
 type itGetter = func(context.Context, string) (io.Reader, error)
 
+// Iterator visits the keys in a range by walking a cursor over the ordered
+// database keys, so iterators only pay for the keys they visit. Keys that are
+// accessed by a transaction, but are not in the database yet, are merged from
+// a separate sorted list.
 type Iterator struct {
 	getter itGetter
 
-	keys []string
+	// handle is the snapshot or transaction handle, which is checked at the end
+	// of the range, because keys can be removed once the handle expires.
+	handle *handle
+
+	begin, end string
+	descending bool
 
-	i, incr int
+	cursor *ordmap.Cursor[*multival.MultiValue]
+
+	// local holds sorted keys in the range that are local to a transaction and
+	// li is the index of the current local key.
+	local []string
+	li    int
 }
 
-func newIterator(getter itGetter, keys []string, descending bool) *Iterator {
+func newIterator(getter itGetter, h *handle, cursor *ordmap.Cursor[*multival.MultiValue], begin, end string, local []string, descending bool) *Iterator {
 	it := &Iterator{
-		getter: getter,
-		keys:   keys,
-		i:      0,
-		incr:   1,
+		getter:     getter,
+		handle:     h,
+		begin:      begin,
+		end:        end,
+		descending: descending,
+		cursor:     cursor,
+		local:      local,
 	}
 	if descending {
-		it.i = len(keys) - 1
-		it.incr = -1
+		if end == "" {
+			cursor.Last()
+		} else {
+			cursor.SeekLT(end)
+		}
+		it.li = len(local) - 1
+	} else {
+		cursor.SeekGE(begin)
+		it.li = 0
 	}
 	return it
 }
 
-func (it *Iterator) Fetch(ctx context.Context, advance bool) (string, io.Reader, error) {
-	stop := func() bool {
-		if it.incr > 0 {
-			return it.i < len(it.keys)
+// current returns the key at the current iterator position, which is the
+// nearest of the current cursor key and the current local key.
+func (it *Iterator) current() (string, bool) {
+	key, ok := "", false
+	if it.cursor.Valid() {
+		k := it.cursor.Key()
+		if (it.descending && k >= it.begin) || (!it.descending && (it.end == "" || k < it.end)) {
+			key, ok = k, true
+		}
+	}
+	if it.li >= 0 && it.li < len(it.local) {
+		k := it.local[it.li]
+		if !ok || (it.descending && k > key) || (!it.descending && k < key) {
+			key, ok = k, true
 		}
-		return it.i >= 0
 	}
+	return key, ok
+}
 
+// step moves the iterator position past the input key, which must be the
+// current key.
+func (it *Iterator) step(key string) {
+	if it.cursor.Valid() && it.cursor.Key() == key {
+		if it.descending {
+			it.cursor.Prev()
+		} else {
+			it.cursor.Next()
+		}
+	}
+	if it.li >= 0 && it.li < len(it.local) && it.local[it.li] == key {
+		if it.descending {
+			it.li--
+		} else {
+			it.li++
+		}
+	}
+}
+
+func (it *Iterator) Fetch(ctx context.Context, advance bool) (string, io.Reader, error) {
 	if advance {
-		it.i += it.incr
+		if key, ok := it.current(); ok {
+			it.step(key)
+		}
 	}
 
-	for ; stop(); it.i += it.incr {
-		key := it.keys[it.i]
-		if value, err := it.getter(ctx, key); err == nil {
+	for key, ok := it.current(); ok; key, ok = it.current() {
+		value, err := it.getter(ctx, key)
+		if err == nil {
 			return key, value, nil
 		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", nil, err
+		}
+		it.step(key)
 	}
 
+	if err := it.handle.check(); err != nil {
+		return "", nil, err
+	}
 	return "", nil, io.EOF
 }
+
+// Seek moves the iterator to the smallest key in the range that is greater
+// than or equal to the input key for ascending iterators and to the largest
+// key in the range that is lesser than or equal to the input key for
+// descending iterators. Keys outside the range are clamped to the range.
+func (it *Iterator) Seek(ctx context.Context, key string) error {
+	if it.descending {
+		if it.end != "" && key >= it.end {
+			it.cursor.SeekLT(it.end)
+		} else {
+			it.cursor.SeekLE(key)
+		}
+		i, found := slices.BinarySearch(it.local, key)
+		if !found {
+			i--
+		}
+		it.li = i
+		return nil
+	}
+
+	if key < it.begin {
+		key = it.begin
+	}
+	it.cursor.SeekGE(key)
+	it.li, _ = slices.BinarySearch(it.local, key)
+	return nil
+}
+
+// countKeys returns the number of keys visited by an iterator.
+func countKeys(ctx context.Context, it kv.Iterator) (int64, error) {
+	var n int64
+	for _, _, err := it.Fetch(ctx, false); err == nil; _, _, err = it.Fetch(ctx, true) {
+		n++
+	}
+	if _, _, err := it.Fetch(ctx, false); err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+	return n, nil
+}
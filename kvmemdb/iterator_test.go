@@ -0,0 +1,164 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bvkgo/kv"
+)
+
+func TestSeek(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := New()
+	set := func(ctx context.Context, rw kv.ReadWriter) error {
+		for _, k := range []string{"b", "d", "f", "h"} {
+			if err := rw.Set(ctx, k, strings.NewReader(k)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := kv.WithReadWriter(ctx, db, set); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db.NewTransaction(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Local keys are merged with the committed keys after a seek too.
+	for _, k := range []string{"c", "g"} {
+		if err := tx.Set(ctx, k, strings.NewReader(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := func(it kv.Iterator, seek, want string) {
+		t.Helper()
+		if err := it.(kv.Seeker).Seek(ctx, seek); err != nil {
+			t.Fatal(err)
+		}
+		var keys []string
+		for k, _, err := it.Fetch(ctx, false); err == nil; k, _, err = it.Fetch(ctx, true) {
+			keys = append(keys, k)
+		}
+		if _, _, err := it.Fetch(ctx, false); !errors.Is(err, io.EOF) {
+			t.Fatal(err)
+		}
+		if got := strings.Join(keys, ","); got != want {
+			t.Fatalf("seek %q: want %s, got %s", seek, want, got)
+		}
+	}
+
+	asc, err := tx.Ascend(ctx, "c", "h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(asc, "e", "f,g")
+	check(asc, "d", "d,f,g")
+	check(asc, "a", "c,d,f,g")
+	check(asc, "z", "")
+
+	desc, err := tx.Descend(ctx, "c", "h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(desc, "e", "d,c")
+	check(desc, "f", "f,d,c")
+	check(desc, "z", "g,f,d,c")
+	check(desc, "a", "")
+}
+
+func TestKeysOnly(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := New()
+	if err := db.RegisterMerge("m", BytesAppend); err != nil {
+		t.Fatal(err)
+	}
+	set := func(ctx context.Context, rw kv.ReadWriter) error {
+		for _, k := range []string{"a", "b", "c", "d"} {
+			if err := rw.Set(ctx, k, strings.NewReader(k)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := kv.WithReadWriter(ctx, db, set); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db.NewTransaction(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := tx.Delete(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.(kv.Merger).Merge(ctx, "m", strings.NewReader("x")); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := func(it kv.Iterator) string {
+		t.Helper()
+		var ks []string
+		for k, v, err := it.Fetch(ctx, false); err == nil; k, v, err = it.Fetch(ctx, true) {
+			if v != nil {
+				t.Fatalf("want nil value for key %s", k)
+			}
+			ks = append(ks, k)
+		}
+		return strings.Join(ks, ",")
+	}
+
+	asc, err := tx.(kv.KeyRanger).AscendKeys(ctx, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := keys(asc); s != "a,c,d,m" {
+		t.Fatalf("want a,c,d,m, got %s", s)
+	}
+	desc, err := tx.(kv.KeyRanger).DescendKeys(ctx, "b", "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := keys(desc); s != "d,c" {
+		t.Fatalf("want d,c, got %s", s)
+	}
+	if n, err := tx.(kv.Counter).Count(ctx, "b", ""); err != nil || n != 3 {
+		t.Fatalf("want 3 keys, got %d, %v", n, err)
+	}
+
+	snap, err := db.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Discard(ctx)
+
+	scan, err := kv.ScanKeys(ctx, snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := keys(scan); s != "a,b,c,d" {
+		t.Fatalf("want a,b,c,d, got %s", s)
+	}
+	if n, err := kv.Count(ctx, snap, "", ""); err != nil || n != 4 {
+		t.Fatalf("want 4 keys, got %d, %v", n, err)
+	}
+	if _, err := kv.Count(ctx, snap, "c", "a"); err == nil {
+		t.Fatalf("want error for an invalid range")
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kvmemdb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bvkgo/kv/internal/multival"
+)
+
+// MergeFunc combines a merge operand with the current value of a key and
+// returns the new value. Exists is false when the key doesn't exist, in which
+// case value is nil. Merge functions must not modify or retain the input
+// slices.
+type MergeFunc func(key string, value []byte, exists bool, operand []byte) ([]byte, error)
+
+// Int64Add is a merge function for counters. Values and operands are signed
+// decimal integers, which are added together. Keys that don't exist are
+// treated as zero.
+func Int64Add(key string, value []byte, exists bool, operand []byte) ([]byte, error) {
+	delta, err := strconv.ParseInt(string(operand), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid int64 operand for key %q: %w", key, os.ErrInvalid)
+	}
+	var current int64
+	if exists {
+		if current, err = strconv.ParseInt(string(value), 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid int64 value for key %q: %w", key, os.ErrInvalid)
+		}
+	}
+	return strconv.AppendInt(nil, current+delta, 10), nil
+}
+
+// BytesAppend is a merge function for append-only values. Operands are
+// appended to the current value. Keys that don't exist are treated as empty.
+func BytesAppend(key string, value []byte, exists bool, operand []byte) ([]byte, error) {
+	data := make([]byte, 0, len(value)+len(operand))
+	data = append(data, value...)
+	return append(data, operand...), nil
+}
+
+// RegisterMerge registers a merge function for all keys with the given
+// prefix. When prefixes of multiple registrations match a key, function with
+// the longest prefix is used. Returns os.ErrExist if a function is already
+// registered for the prefix.
+func (db *DB) RegisterMerge(prefix string, fn MergeFunc) error {
+	if fn == nil {
+		return os.ErrInvalid
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.mergeFuncs[prefix]; ok {
+		return fmt.Errorf("merge function for prefix %q: %w", prefix, os.ErrExist)
+	}
+	if db.mergeFuncs == nil {
+		db.mergeFuncs = make(map[string]MergeFunc)
+	}
+	db.mergeFuncs[prefix] = fn
+	return nil
+}
+
+// mergeFunc returns the merge function registered with the longest matching
+// prefix for the key.
+func (db *DB) mergeFunc(key string) (MergeFunc, bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var fn MergeFunc
+	match := -1
+	for prefix, f := range db.mergeFuncs {
+		if len(prefix) > match && strings.HasPrefix(key, prefix) {
+			fn, match = f, len(prefix)
+		}
+	}
+	return fn, fn != nil
+}
+
+// pendingMerge holds the merge operands for a key that are not resolved yet.
+type pendingMerge struct {
+	fn       MergeFunc
+	operands [][]byte
+}
+
+// resolve applies all pending operands to a value in their order.
+func (pm *pendingMerge) resolve(key string, value []byte, exists bool) ([]byte, error) {
+	for _, operand := range pm.operands {
+		v, err := pm.fn(key, value, exists, operand)
+		if err != nil {
+			return nil, err
+		}
+		value, exists = v, true
+	}
+	return value, nil
+}
+
+// Merge combines the operand with the value of a key using the merge function
+// registered for the key with RegisterMerge. Unlike Get and Set, Merge doesn't
+// add the key to the read set of the transaction, so concurrent merges to the
+// same key do not conflict. Operands are resolved against the latest
+// committed value at commit time, so errors from the merge function are
+// reported by the Commit.
+//
+// Reading a merged key in the same transaction resolves the operands against
+// the value visible to the transaction, which adds the key to the read set.
+func (t *Transaction) Merge(ctx context.Context, key string, operand io.Reader) error {
+	if len(key) == 0 {
+		return os.ErrInvalid
+	}
+	if t.readOnly {
+		return fmt.Errorf("transaction is read-only: %w", os.ErrPermission)
+	}
+	if err := t.handle.check(); err != nil {
+		return err
+	}
+
+	fn, ok := t.db.mergeFunc(key)
+	if !ok {
+		return fmt.Errorf("no merge function is registered for key %q: %w", key, os.ErrInvalid)
+	}
+
+	data, err := io.ReadAll(operand)
+	if err != nil {
+		return err
+	}
+
+	// Values written by this transaction are merged immediately.
+	if v, ok := t.accesses[key]; ok && v.Version == t.version {
+		merged, err := fn(key, v.Data, v.Visible(t.readTime), data)
+		if err != nil {
+			return err
+		}
+		v.Data = merged
+		v.Deleted = false
+		return nil
+	}
+
+	if t.merges == nil {
+		t.merges = make(map[string]*pendingMerge)
+	}
+	pm, ok := t.merges[key]
+	if !ok {
+		pm = &pendingMerge{fn: fn}
+		t.merges[key] = pm
+	}
+	pm.operands = append(pm.operands, data)
+	t.addWrite(key)
+	return nil
+}
+
+// resolveMergesLocked resolves the pending merges from a transaction against
+// the latest committed values and returns the new values with the given
+// commit version.
+//
+// Caller must hold the db.mu lock.
+func (db *DB) resolveMergesLocked(tx *Transaction, version, now int64) ([]write, error) {
+	var writes []write
+	for key, pm := range tx.merges {
+		var base *multival.Value
+		if mv, ok := db.store.Load(key); ok {
+			base, _ = mv.Fetch(db.maxCommitVersion)
+		}
+		exists := base != nil && base.Visible(now) && !tx.rangeDeleted(key)
+
+		var data []byte
+		if exists {
+			data = base.Data
+		}
+		merged, err := pm.resolve(key, data, exists)
+		if err != nil {
+			return nil, fmt.Errorf("could not merge key %q: %w", key, err)
+		}
+
+		value := &multival.Value{Version: version, Data: merged}
+		if exists {
+			value.ExpiresAt = base.ExpiresAt
+		}
+		writes = append(writes, write{key: key, value: value})
+	}
+	return writes, nil
+}
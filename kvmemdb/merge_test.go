@@ -0,0 +1,173 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bvkgo/kv"
+)
+
+func TestMerge(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := New()
+	if err := db.RegisterMerge("counter/", Int64Add); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RegisterMerge("log/", BytesAppend); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RegisterMerge("log/", BytesAppend); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("want ErrExist, got %v", err)
+	}
+
+	get := func(key string) string {
+		snap, err := db.NewSnapshot(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer snap.Discard(ctx)
+
+		r, err := snap.Get(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	// Concurrent merges to the same keys must not conflict.
+	var txs []kv.Transaction
+	for i := 0; i < 10; i++ {
+		tx, err := db.NewTransaction(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txs = append(txs, tx)
+	}
+	for i, tx := range txs {
+		if err := tx.(kv.Merger).Merge(ctx, "counter/a", strings.NewReader(strconv.Itoa(i+1))); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.(kv.Merger).Merge(ctx, "log/a", strings.NewReader("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tx := range txs {
+		if err := tx.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v := get("counter/a"); v != "55" {
+		t.Fatalf("want 55, got %s", v)
+	}
+	if v := get("log/a"); v != "xxxxxxxxxx" {
+		t.Fatalf("want 10 appends, got %s", v)
+	}
+
+	// Reads in the same transaction include the pending merges and merges on
+	// the local writes are applied immediately.
+	tx, err := db.NewTransaction(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merger := tx.(kv.Merger)
+	if err := merger.Merge(ctx, "counter/a", strings.NewReader("-5")); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := tx.Get(ctx, "counter/a"); err != nil {
+		t.Fatal(err)
+	} else if data, _ := io.ReadAll(r); string(data) != "50" {
+		t.Fatalf("want 50, got %s", data)
+	}
+	if err := tx.Set(ctx, "counter/b", strings.NewReader("100")); err != nil {
+		t.Fatal(err)
+	}
+	if err := merger.Merge(ctx, "counter/b", strings.NewReader("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := merger.Merge(ctx, "other", strings.NewReader("1")); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("want ErrInvalid for keys without a merge function, got %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if v := get("counter/a"); v != "50" {
+		t.Fatalf("want 50, got %s", v)
+	}
+	if v := get("counter/b"); v != "101" {
+		t.Fatalf("want 101, got %s", v)
+	}
+
+	// Merge function errors are reported at the commit.
+	bad := func(ctx context.Context, rw kv.ReadWriter) error {
+		return rw.(kv.Merger).Merge(ctx, "counter/a", strings.NewReader("x"))
+	}
+	if err := kv.WithReadWriter(ctx, db, bad); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("want ErrInvalid, got %v", err)
+	}
+}
+
+func TestMergeCounter(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := New()
+	if err := db.RegisterMerge("", Int64Add); err != nil {
+		t.Fatal(err)
+	}
+
+	const nworkers, nincrements = 10, 100
+	var wg sync.WaitGroup
+	for i := 0; i < nworkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < nincrements; j++ {
+				tx, err := db.NewTransaction(ctx)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if err := tx.(kv.Merger).Merge(ctx, "counter", strings.NewReader("1")); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := tx.Commit(ctx); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if s := db.Stats(); s.Conflicts != 0 {
+		t.Fatalf("want no conflicts, got %d", s.Conflicts)
+	}
+	snap, err := db.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Discard(ctx)
+	r, err := snap.Get(ctx, "counter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(r); string(data) != strconv.Itoa(nworkers*nincrements) {
+		t.Fatalf("want %d, got %s", nworkers*nincrements, data)
+	}
+}
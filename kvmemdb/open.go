@@ -0,0 +1,199 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kvmemdb
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Open opens a durable database in the input directory, creating it if
+// necessary. All commits are saved in a write-ahead log before they are
+// applied, and the database content is checkpointed to the directory
+// periodically.
+//
+// Database state is recovered from the last checkpoint and the write-ahead
+// log. A partially written commit record at the end of the log, for example,
+// due to a crash, is discarded.
+//
+// Databases opened with Open must be closed with the Close method.
+func Open(dir string, opts *Options) (*DB, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	db := NewWithOptions(opts)
+	db.dir = dir
+
+	cfile := filepath.Join(dir, checkpointFile)
+	if fp, err := os.Open(cfile); err == nil {
+		err := restoreInto(context.Background(), db, bufio.NewReader(fp))
+		fp.Close()
+		if err != nil {
+			return nil, fmt.Errorf("could not load checkpoint %q: %w", cfile, err)
+		}
+		db.checkpointVersion = db.maxCommitVersion
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	segments, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	apply := func(version int64, writes []write) error {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+
+		if version <= db.maxCommitVersion {
+			return nil // Already included in the checkpoint.
+		}
+		if version != db.maxCommitVersion+1 {
+			return fmt.Errorf("wal commit version %d is not contiguous with %d: %w", version, db.maxCommitVersion, os.ErrInvalid)
+		}
+		db.applyLocked(version, db.minVersionLocked(), writes)
+		return nil
+	}
+	for i, start := range segments {
+		last := i == len(segments)-1
+		if err := replaySegment(filepath.Join(dir, segmentName(start)), last, apply); err != nil {
+			return nil, err
+		}
+	}
+
+	if db.lastTxVersion < db.maxCommitVersion {
+		db.lastTxVersion = db.maxCommitVersion
+	}
+
+	start := db.maxCommitVersion + 1
+	if len(segments) > 0 {
+		start = segments[len(segments)-1]
+	}
+	w, err := openWAL(dir, start, db.opts.WALSync)
+	if err != nil {
+		return nil, err
+	}
+	db.wal = w
+
+	db.wg.Add(1)
+	go db.goBackground()
+	return db, nil
+}
+
+// Close stops the background work and closes the write-ahead log, if any.
+// Transactions cannot be committed after the database is closed.
+func (db *DB) Close() error {
+	db.mu.Lock()
+	if db.closed {
+		db.mu.Unlock()
+		return os.ErrClosed
+	}
+	db.closed = true
+	db.mu.Unlock()
+
+	close(db.done)
+	db.wg.Wait()
+
+	if db.wal != nil {
+		return db.wal.close()
+	}
+	return nil
+}
+
+// Checkpoint saves the current database content to the database directory
+// and removes the write-ahead log segments that are no longer necessary.
+func (db *DB) Checkpoint(ctx context.Context) error {
+	if db.wal == nil {
+		return fmt.Errorf("database is not opened with a directory: %w", os.ErrInvalid)
+	}
+
+	db.checkpointMu.Lock()
+	defer db.checkpointMu.Unlock()
+
+	// Rotate the wal under the db lock, so that the new segment begins right
+	// after the snapshot version.
+	db.mu.Lock()
+	if db.closed {
+		db.mu.Unlock()
+		return os.ErrClosed
+	}
+	if db.maxCommitVersion == db.checkpointVersion {
+		db.mu.Unlock()
+		return nil
+	}
+	if err := db.wal.rotate(db.maxCommitVersion + 1); err != nil {
+		db.mu.Unlock()
+		return err
+	}
+	db.mu.Unlock()
+
+	s, header := db.backupSnapshot()
+	defer s.Discard(ctx)
+
+	write := func(w io.Writer) error {
+		return writeBackup(ctx, w, s, header, nil)
+	}
+	if err := writeFileAtomic(filepath.Join(db.dir, checkpointFile), write); err != nil {
+		return err
+	}
+	db.checkpointVersion = header.MaxCommitVersion
+
+	// Remove the segments with all commits included in the checkpoint. A
+	// segment holds all commits from it's start version till the start
+	// version of the next segment.
+	segments, err := listSegments(db.dir)
+	if err != nil {
+		return err
+	}
+	for i := 0; i+1 < len(segments); i++ {
+		if segments[i+1] > header.MaxCommitVersion+1 {
+			break
+		}
+		if err := os.Remove(filepath.Join(db.dir, segmentName(segments[i]))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// goBackground syncs the wal and takes the checkpoints periodically.
+func (db *DB) goBackground() {
+	defer db.wg.Done()
+
+	var syncCh <-chan time.Time
+	if db.opts.WALSync == SyncInterval {
+		ticker := time.NewTicker(db.opts.WALSyncInterval)
+		defer ticker.Stop()
+		syncCh = ticker.C
+	}
+
+	var checkpointCh <-chan time.Time
+	if db.opts.CheckpointInterval > 0 {
+		ticker := time.NewTicker(db.opts.CheckpointInterval)
+		defer ticker.Stop()
+		checkpointCh = ticker.C
+	}
+
+	for {
+		select {
+		case <-db.done:
+			return
+		case <-syncCh:
+			if err := db.wal.syncFile(); err != nil {
+				log.Printf("kvmemdb: could not sync the wal: %v", err)
+			}
+		case <-checkpointCh:
+			if err := db.Checkpoint(context.Background()); err != nil && !errors.Is(err, os.ErrClosed) {
+				log.Printf("kvmemdb: could not checkpoint the database: %v", err)
+			}
+		}
+	}
+}
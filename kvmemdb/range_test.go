@@ -0,0 +1,146 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bvkgo/kv"
+)
+
+func TestDeleteRange(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := New()
+	keys := func(r kv.Reader) string {
+		it, err := r.Scan(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ks []string
+		for k, _, err := it.Fetch(ctx, false); err == nil; k, _, err = it.Fetch(ctx, true) {
+			ks = append(ks, k)
+		}
+		if _, _, err := it.Fetch(ctx, false); !errors.Is(err, io.EOF) {
+			t.Fatal(err)
+		}
+		return strings.Join(ks, ",")
+	}
+
+	init := func(ctx context.Context, rw kv.ReadWriter) error {
+		for _, k := range []string{"a", "b", "c", "d", "e", "f"} {
+			if err := rw.Set(ctx, k, strings.NewReader(k)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := kv.WithReadWriter(ctx, db, init); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db.NewTransaction(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Set(ctx, "cc", strings.NewReader("cc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.(kv.RangeDeleter).DeleteRange(ctx, "b", "e"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Set(ctx, "d", strings.NewReader("new")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Get(ctx, "c"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("want ErrNotExist, got %v", err)
+	}
+	if n := len(tx.(*Transaction).accesses); n != 2 {
+		t.Fatalf("want only the written keys to be tracked, got %d", n)
+	}
+	if s := keys(tx); s != "a,d,e,f" {
+		t.Fatalf("want a,d,e,f in the transaction, got %s", s)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := db.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := keys(snap); s != "a,d,e,f" {
+		t.Fatalf("want a,d,e,f, got %s", s)
+	}
+	r, err := snap.Get(ctx, "d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(r); string(data) != "new" {
+		t.Fatalf("want new, got %s", data)
+	}
+	snap.Discard(ctx)
+
+	// Keys created or updated in the range by other transactions after the
+	// DeleteRange are not deleted silently.
+	for _, key := range []string{"b", "a"} {
+		tx, err = db.NewTransaction(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.(kv.RangeDeleter).DeleteRange(ctx, "", "e"); err != nil {
+			t.Fatal(err)
+		}
+		set := func(ctx context.Context, rw kv.ReadWriter) error {
+			return rw.Set(ctx, key, strings.NewReader(key))
+		}
+		if err := kv.WithReadWriter(ctx, db, set); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(ctx); !errors.Is(err, kv.ErrConflict) {
+			t.Fatalf("%s: want ErrConflict, got %v", key, err)
+		}
+	}
+
+	// Keys updated outside the range do not conflict.
+	tx, err = db.NewTransaction(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.(kv.RangeDeleter).DeleteRange(ctx, "", "e"); err != nil {
+		t.Fatal(err)
+	}
+	set := func(ctx context.Context, rw kv.ReadWriter) error {
+		return rw.Set(ctx, "f", strings.NewReader("new"))
+	}
+	if err := kv.WithReadWriter(ctx, db, set); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err = db.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Discard(ctx)
+
+	if s := keys(snap); s != "e,f" {
+		t.Fatalf("want e,f, got %s", s)
+	}
+
+	if err := kv.WithReadWriter(ctx, db, func(ctx context.Context, rw kv.ReadWriter) error {
+		return rw.(kv.RangeDeleter).DeleteRange(ctx, "e", "b")
+	}); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("want ErrInvalid, got %v", err)
+	}
+}
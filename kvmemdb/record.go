@@ -0,0 +1,201 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kvmemdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/bvkgo/kv/internal/multival"
+)
+
+// Records are the unit of data in the write-ahead log files. Every record
+// is framed with a fixed size header holding the payload size and the payload
+// checksum, so that partially written records can be detected.
+//
+//	+------------+--------------+---------+
+//	| size (4B)  | crc32c (4B)  | payload |
+//	+------------+--------------+---------+
+
+const recordHeaderSize = 8
+
+// maxRecordSize limits the payload size to catch corrupted size fields.
+const maxRecordSize = 1 << 30
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errChecksum is returned when a record's payload doesn't match it's
+// checksum.
+var errChecksum = errors.New("record checksum mismatch")
+
+// writeRecord writes the payload as a single record. Returns number of bytes
+// written.
+func writeRecord(w io.Writer, payload []byte) (int, error) {
+	var header [recordHeaderSize]byte
+	binary.LittleEndian.PutUint32(header[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(header[4:8], crc32.Checksum(payload, crcTable))
+	n, err := w.Write(header[:])
+	if err != nil {
+		return n, err
+	}
+	m, err := w.Write(payload)
+	return n + m, err
+}
+
+// readRecord reads the next record payload. Returns io.EOF when there are no
+// more records and io.ErrUnexpectedEOF if the last record is incomplete.
+func readRecord(r io.Reader) ([]byte, error) {
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.LittleEndian.Uint32(header[0:4])
+	if size > maxRecordSize {
+		return nil, fmt.Errorf("record size %d is too large: %w", size, errChecksum)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if crc32.Checksum(payload, crcTable) != binary.LittleEndian.Uint32(header[4:8]) {
+		return nil, errChecksum
+	}
+	return payload, nil
+}
+
+const (
+	valueDeleted = 1 << iota
+	valueExpires
+)
+
+// appendValue appends the binary encoding of a key and it's value to the
+// input buffer.
+func appendValue(buf []byte, key string, v *multival.Value) []byte {
+	var flags byte
+	if v.Deleted {
+		flags |= valueDeleted
+	}
+	if v.ExpiresAt != 0 {
+		flags |= valueExpires
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(key)))
+	buf = append(buf, key...)
+	buf = binary.AppendVarint(buf, v.Version)
+	buf = append(buf, flags)
+	if v.ExpiresAt != 0 {
+		buf = binary.AppendVarint(buf, v.ExpiresAt)
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(v.Data)))
+	buf = append(buf, v.Data...)
+	return buf
+}
+
+// decoder parses the binary encoded data. Errors are sticky, so callers can
+// check for the error once after decoding all fields.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) bytes(n uint64) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if uint64(len(d.buf)) < n {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	v := d.buf[:n:n]
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) byte() byte {
+	if v := d.bytes(1); v != nil {
+		return v[0]
+	}
+	return 0
+}
+
+// value decodes a key and it's value encoded by the appendValue function.
+func (d *decoder) value() (string, *multival.Value) {
+	key := string(d.bytes(d.uvarint()))
+	v := &multival.Value{Version: d.varint()}
+	flags := d.byte()
+	if d.err == nil && flags&^(valueDeleted|valueExpires) != 0 {
+		d.err = fmt.Errorf("unknown value flags %#x: %w", flags, errChecksum)
+	}
+	if flags&valueExpires != 0 {
+		v.ExpiresAt = d.varint()
+	}
+	if data := d.bytes(d.uvarint()); len(data) > 0 {
+		v.Data = data
+	}
+	v.Deleted = flags&valueDeleted != 0
+	if d.err != nil {
+		return "", nil
+	}
+	return key, v
+}
+
+// appendCommit appends the binary encoding of a commit record with the commit
+// version and all values written by the commit.
+func appendCommit(buf []byte, version int64, writes []write) []byte {
+	buf = binary.AppendVarint(buf, version)
+	buf = binary.AppendUvarint(buf, uint64(len(writes)))
+	for _, w := range writes {
+		buf = appendValue(buf, w.key, w.value)
+	}
+	return buf
+}
+
+// decodeCommit decodes a commit record encoded by the appendCommit function.
+func decodeCommit(payload []byte) (int64, []write, error) {
+	d := &decoder{buf: payload}
+	version := d.varint()
+	n := d.uvarint()
+	if d.err == nil && n > uint64(len(d.buf)) {
+		return 0, nil, fmt.Errorf("invalid number of writes %d: %w", n, errChecksum)
+	}
+	writes := make([]write, 0, n)
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		key, value := d.value()
+		writes = append(writes, write{key: key, value: value})
+	}
+	if d.err != nil {
+		return 0, nil, d.err
+	}
+	return version, writes, nil
+}
@@ -7,69 +7,200 @@ import (
 	"context"
 	"io"
 	"os"
-	"slices"
-	"sort"
 
 	"github.com/bvkgo/kv"
+	"github.com/bvkgo/kv/internal/multival"
 )
 
 type Snapshot struct {
 	db *DB
 
 	lastCommitVersion int64
+
+	// readTime holds the snapshot creation time in unix nanoseconds. Values
+	// that expire before this time are not visible to the snapshot.
+	readTime int64
+
+	// handle is non-nil when the handle tracking is enabled.
+	handle *handle
+}
+
+// Version returns the commit version of the database data visible through
+// the snapshot.
+func (s *Snapshot) Version() int64 {
+	return s.lastCommitVersion
+}
+
+// ReadVersion is same as Version.
+func (s *Snapshot) ReadVersion() int64 {
+	return s.lastCommitVersion
+}
+
+// CommitVersion always returns false because snapshots are never committed.
+func (s *Snapshot) CommitVersion() (int64, bool) {
+	return 0, false
 }
 
 func (s *Snapshot) Discard(ctx context.Context) error {
 	if s.db == nil {
 		return os.ErrClosed
 	}
-	s.db.releasePin(s.lastCommitVersion)
+	err := s.db.releasePin(s.lastCommitVersion, s.handle)
 	s.db = nil
-	return nil
+	return err
 }
 
 func (s *Snapshot) Get(ctx context.Context, key string) (io.Reader, error) {
 	if len(key) == 0 {
 		return nil, os.ErrInvalid
 	}
+	value, err := s.fetch(key)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, os.ErrNotExist
+	}
+	return bytes.NewReader(value.Data), nil
+}
 
+// fetch returns the value of a key visible to the snapshot or nil if the key
+// doesn't exist. Older versions can be removed as soon as the handle expires,
+// so the handle is checked again after the read.
+func (s *Snapshot) fetch(key string) (*multival.Value, error) {
+	if err := s.handle.check(); err != nil {
+		return nil, err
+	}
+	var value *multival.Value
 	if mv, ok := s.db.store.Load(key); ok {
-		if value, ok := mv.Fetch(s.lastCommitVersion); ok {
-			if !value.Deleted {
-				return bytes.NewReader(value.Data), nil
-			}
+		if v, ok := mv.Fetch(s.lastCommitVersion); ok && v.Visible(s.readTime) {
+			value = v
 		}
 	}
-	return nil, os.ErrNotExist
+	if err := s.handle.check(); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// MultiGet reads multiple keys from the snapshot.
+func (s *Snapshot) MultiGet(ctx context.Context, keys []string) ([]io.Reader, []error, error) {
+	if err := s.handle.check(); err != nil {
+		return nil, nil, err
+	}
+	values := make([]io.Reader, len(keys))
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		values[i], errs[i] = s.Get(ctx, key)
+	}
+	return values, errs, nil
 }
 
 func (s *Snapshot) Ascend(ctx context.Context, begin, end string) (kv.Iterator, error) {
-	keys := s.db.keys(nil)
-	sort.Strings(keys)
-	i, n := 0, len(keys)
-	if begin != "" {
-		i, _ = slices.BinarySearch(keys, begin)
+	if end != "" && begin > end {
+		return nil, os.ErrInvalid
 	}
-	if end != "" {
-		n, _ = slices.BinarySearch(keys, end)
+	if err := s.handle.check(); err != nil {
+		return nil, err
 	}
-	return newIterator(s.Get, keys[i:n], false /* descending */), nil
+	return newIterator(s.Get, s.handle, s.db.store.Cursor(), begin, end, nil, false /* descending */), nil
 }
 
 func (s *Snapshot) Descend(ctx context.Context, begin, end string) (kv.Iterator, error) {
-	keys := s.db.keys(nil)
-	sort.Strings(keys)
-	i, n := 0, len(keys)
-	if begin != "" {
-		i, _ = slices.BinarySearch(keys, begin)
+	if end != "" && begin > end {
+		return nil, os.ErrInvalid
 	}
-	if end != "" {
-		n, _ = slices.BinarySearch(keys, end)
+	if err := s.handle.check(); err != nil {
+		return nil, err
 	}
-	return newIterator(s.Get, keys[i:n], true /* descending */), nil
+	return newIterator(s.Get, s.handle, s.db.store.Cursor(), begin, end, nil, true /* descending */), nil
 }
 
 func (s *Snapshot) Scan(ctx context.Context) (kv.Iterator, error) {
-	keys := s.db.keys(nil)
-	return newIterator(s.Get, keys, false /* descending */), nil
+	if err := s.handle.check(); err != nil {
+		return nil, err
+	}
+	return newIterator(s.Get, s.handle, s.db.store.Cursor(), "", "", nil, false /* descending */), nil
+}
+
+// getKey is same as Get, but returns a nil value, so that the keys-only
+// iterators do not need to wrap the values.
+func (s *Snapshot) getKey(ctx context.Context, key string) (io.Reader, error) {
+	value, err := s.fetch(key)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, os.ErrNotExist
+	}
+	return nil, nil
+}
+
+func (s *Snapshot) AscendKeys(ctx context.Context, begin, end string) (kv.Iterator, error) {
+	if end != "" && begin > end {
+		return nil, os.ErrInvalid
+	}
+	if err := s.handle.check(); err != nil {
+		return nil, err
+	}
+	return newIterator(s.getKey, s.handle, s.db.store.Cursor(), begin, end, nil, false /* descending */), nil
+}
+
+func (s *Snapshot) DescendKeys(ctx context.Context, begin, end string) (kv.Iterator, error) {
+	if end != "" && begin > end {
+		return nil, os.ErrInvalid
+	}
+	if err := s.handle.check(); err != nil {
+		return nil, err
+	}
+	return newIterator(s.getKey, s.handle, s.db.store.Cursor(), begin, end, nil, true /* descending */), nil
+}
+
+func (s *Snapshot) ScanKeys(ctx context.Context) (kv.Iterator, error) {
+	if err := s.handle.check(); err != nil {
+		return nil, err
+	}
+	return newIterator(s.getKey, s.handle, s.db.store.Cursor(), "", "", nil, false /* descending */), nil
+}
+
+// Count returns the number of keys in a range visible to the snapshot.
+func (s *Snapshot) Count(ctx context.Context, begin, end string) (int64, error) {
+	it, err := s.AscendKeys(ctx, begin, end)
+	if err != nil {
+		return 0, err
+	}
+	return countKeys(ctx, it)
+}
+
+// History returns an iterator over the retained versions of a key that are
+// visible to the snapshot.
+func (s *Snapshot) History(ctx context.Context, key string) (kv.ChangeIterator, error) {
+	if len(key) == 0 {
+		return nil, os.ErrInvalid
+	}
+	if err := s.handle.check(); err != nil {
+		return nil, err
+	}
+
+	var changes []*kv.Change
+	if mv, ok := s.db.store.Load(key); ok {
+		for _, v := range mv.Values(s.lastCommitVersion) {
+			c := &kv.Change{
+				Key:     key,
+				Deleted: v.Deleted,
+				Version: v.Version,
+			}
+			if !v.Deleted {
+				c.Value = bytes.Clone(v.Data)
+				if c.Value == nil {
+					c.Value = []byte{}
+				}
+			}
+			changes = append(changes, c)
+		}
+	}
+	if err := s.handle.check(); err != nil {
+		return nil, err
+	}
+	return kv.NewChangeList(changes), nil
 }
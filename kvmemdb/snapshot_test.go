@@ -0,0 +1,125 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bvkgo/kv"
+)
+
+func TestSnapshotAt(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := NewWithOptions(&Options{RetainVersions: 10})
+
+	versions := make(map[int64]int)
+	for i := 0; i < 50; i++ {
+		set := func(ctx context.Context, rw kv.ReadWriter) error {
+			return rw.Set(ctx, "key", strings.NewReader(strconv.Itoa(i)))
+		}
+		if err := kv.WithReadWriter(ctx, db, set); err != nil {
+			t.Fatal(err)
+		}
+
+		snap, _ := db.NewSnapshot(ctx)
+		versions[snap.(*Snapshot).Version()] = i
+		snap.Discard(ctx)
+	}
+
+	last := db.maxCommitVersion
+	for version, want := range versions {
+		snap, err := db.NewSnapshotAt(ctx, version)
+		if version <= last-20 {
+			if !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("version %d: want ErrNotExist, got %v", version, err)
+			}
+			continue
+		}
+		if version <= last-10 {
+			// Version may or may not be retained.
+			if err == nil {
+				snap.Discard(ctx)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("version %d: %v", version, err)
+		}
+		v, err := snap.Get(ctx, "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(v)
+		if got := string(data); got != strconv.Itoa(want) {
+			t.Fatalf("version %d: want %d, got %s", version, want, got)
+		}
+		snap.Discard(ctx)
+	}
+
+	if _, err := db.NewSnapshotAt(ctx, last+1); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("want ErrInvalid, got %v", err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := NewWithOptions(&Options{RetainVersions: 3})
+	for _, value := range []string{"one", "two", "", "three", "four"} {
+		update := func(ctx context.Context, rw kv.ReadWriter) error {
+			if value == "" {
+				return rw.Delete(ctx, "key")
+			}
+			return rw.Set(ctx, "key", strings.NewReader(value))
+		}
+		if err := kv.WithReadWriter(ctx, db, update); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history := func(version int64) string {
+		snap, err := db.NewSnapshotAt(ctx, version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer snap.Discard(ctx)
+
+		it, err := snap.(kv.Historian).History(ctx, "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer it.Close()
+
+		var items []string
+		for c, err := it.Next(ctx); !errors.Is(err, io.EOF); c, err = it.Next(ctx) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Deleted {
+				items = append(items, fmt.Sprintf("%d:deleted", c.Version))
+			} else {
+				items = append(items, fmt.Sprintf("%d:%s", c.Version, c.Value))
+			}
+		}
+		return strings.Join(items, " ")
+	}
+
+	// Versions older than the retained versions are compacted.
+	if got, want := history(5), "2:two 3:deleted 4:three 5:four"; got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+	if got, want := history(3), "2:two 3:deleted"; got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kvmemdb
+
+import (
+	"expvar"
+	"math"
+	"time"
+
+	"github.com/bvkgo/kv/internal/multival"
+)
+
+// Stats holds the database counters.
+type Stats struct {
+	// Keys is the number of live keys in the database. Deleted and expired
+	// keys are not counted.
+	Keys int64
+
+	// Versions is the number of values held for all keys, including the older
+	// versions and deleted values retained for the snapshots and transactions.
+	Versions int64
+
+	// Bytes is the total size of the values for all versions.
+	Bytes int64
+
+	// Pins is the number of live snapshots and transactions, which prevent
+	// the older versions from being removed.
+	Pins int64
+
+	// OldestPin is the oldest version referenced by a live snapshot or
+	// transaction. It is zero when there are no pins, which can be told apart
+	// from a pin on the version zero by the Pins field.
+	OldestPin int64
+
+	// MaxCommitVersion is the most recent commit version.
+	MaxCommitVersion int64
+
+	// Commits, Rollbacks and Conflicts are the number of transactions
+	// committed, rolled back, and failed with a conflict respectively.
+	Commits   int64
+	Rollbacks int64
+	Conflicts int64
+
+	// GC holds the cumulative stats from all garbage collection passes.
+	GC GCStats
+}
+
+// Stats returns the current database counters.
+func (db *DB) Stats() *Stats {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	s := &Stats{
+		Keys:             db.numKeys - db.numExpiredLocked(time.Now().UnixNano()),
+		Versions:         db.numVersions,
+		Bytes:            db.numBytes,
+		MaxCommitVersion: db.maxCommitVersion,
+		Commits:          db.numCommits.Load(),
+		Rollbacks:        db.numRollbacks.Load(),
+		Conflicts:        db.numConflicts.Load(),
+		GC:               db.gcTotals,
+	}
+	// Version zero is pinned by the snapshots of an empty database, so it
+	// cannot mark the oldest pin as unset.
+	found := false
+	for version, n := range db.pins {
+		s.Pins += int64(n)
+		if !found || version < s.OldestPin {
+			s.OldestPin, found = version, true
+		}
+	}
+	return s
+}
+
+// PublishExpvar exports the database stats as an expvar variable with the
+// given name. Like expvar.Publish, it panics if the name is already in use.
+func (db *DB) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() any { return db.Stats() }))
+}
+
+// updateCountsLocked updates the key and value counters when the multi-value
+// of a key in the store is replaced. Nil or empty multi-values stand for the
+// missing keys.
+//
+// Caller must hold the db.mu lock or the database must not be in use.
+func (db *DB) updateCountsLocked(key string, oldmv, newmv *multival.MultiValue) {
+	db.numKeys += isLive(newmv) - isLive(oldmv)
+	db.numVersions += int64(newmv.Len() - oldmv.Len())
+	db.numBytes += newmv.DataSize() - oldmv.DataSize()
+
+	// Keys are counted as live till they are removed, so the keys with a TTL
+	// are tracked separately to exclude them from the stats after expiry.
+	if v := latest(newmv); v != nil && !v.Deleted && v.ExpiresAt != 0 {
+		if db.expiring == nil {
+			db.expiring = make(map[string]int64)
+		}
+		db.expiring[key] = v.ExpiresAt
+	} else {
+		delete(db.expiring, key)
+	}
+}
+
+// numExpiredLocked returns the number of keys that are counted as live, but
+// have expired at the given time.
+//
+// Caller must hold the db.mu lock.
+func (db *DB) numExpiredLocked(now int64) int64 {
+	var n int64
+	for _, expiresAt := range db.expiring {
+		if expiresAt <= now {
+			n++
+		}
+	}
+	return n
+}
+
+// isLive returns one if the latest value in the multi-value is not deleted;
+// otherwise, returns zero. Expired keys are excluded by the Stats method.
+func isLive(mv *multival.MultiValue) int64 {
+	if v := latest(mv); v != nil && !v.Deleted {
+		return 1
+	}
+	return 0
+}
+
+// latest returns the latest value in the multi-value or nil if there is none.
+func latest(mv *multival.MultiValue) *multival.Value {
+	if mv == nil {
+		return nil
+	}
+	if v, ok := mv.Fetch(math.MaxInt64); ok {
+		return v
+	}
+	return nil
+}
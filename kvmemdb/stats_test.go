@@ -0,0 +1,183 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kvmemdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bvkgo/kv"
+)
+
+func TestStats(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := New()
+	set := func(key, value string) {
+		f := func(ctx context.Context, rw kv.ReadWriter) error {
+			if value == "" {
+				return rw.Delete(ctx, key)
+			}
+			return rw.Set(ctx, key, strings.NewReader(value))
+		}
+		if err := kv.WithReadWriter(ctx, db, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	set("a", "1")
+	set("b", "22")
+	set("c", "333")
+
+	snap, err := db.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set("a", "4444")
+	set("c", "")
+
+	// Create a conflict with two concurrent transactions.
+	tx1, _ := db.NewTransaction(ctx)
+	tx2, _ := db.NewTransaction(ctx)
+	for _, tx := range []kv.Transaction{tx1, tx2} {
+		if _, err := tx.Get(ctx, "b"); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Set(ctx, "b", strings.NewReader("55555")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx1.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Commit(ctx); !errors.Is(err, kv.ErrConflict) {
+		t.Fatalf("want ErrConflict, got %v", err)
+	}
+
+	tx3, _ := db.NewTransaction(ctx)
+	tx3.Rollback(ctx)
+
+	s := db.Stats()
+	want := Stats{
+		Keys:             2,
+		Versions:         6, // a:1,4444 b:22,55555 c:333,deleted
+		Bytes:            1 + 4 + 2 + 5 + 3,
+		Pins:             1,
+		OldestPin:        snap.(*Snapshot).Version(),
+		MaxCommitVersion: 6,
+		Commits:          6,
+		Rollbacks:        1,
+		Conflicts:        1,
+	}
+	if *s != want {
+		t.Fatalf("want %+v, got %+v", want, *s)
+	}
+
+	snap.Discard(ctx)
+	if _, err := db.GC(ctx); err != nil {
+		t.Fatal(err)
+	}
+	s = db.Stats()
+	if s.Keys != 2 || s.Versions != 2 || s.Bytes != 4+5 || s.Pins != 0 || s.OldestPin != 0 {
+		t.Fatalf("unexpected stats after gc: %+v", *s)
+	}
+
+	db.PublishExpvar("kvmemdb-stats-test")
+	var exported Stats
+	if err := json.Unmarshal([]byte(expvar.Get("kvmemdb-stats-test").String()), &exported); err != nil {
+		t.Fatal(err)
+	}
+	if exported != *s {
+		t.Fatalf("want exported stats %+v, got %+v", *s, exported)
+	}
+}
+
+func TestStatsExpiry(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := NewWithOptions(&Options{MaxHandleAge: 10 * time.Millisecond})
+	setTTL := func(key string, ttl time.Duration) {
+		set := func(ctx context.Context, rw kv.ReadWriter) error {
+			return rw.(kv.TTLSetter).SetWithTTL(ctx, key, strings.NewReader("value"), ttl)
+		}
+		if err := kv.WithReadWriter(ctx, db, set); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setTTL("short", 10*time.Millisecond)
+	setTTL("long", time.Hour)
+	if s := db.Stats(); s.Keys != 2 {
+		t.Fatalf("want 2 keys, got %d", s.Keys)
+	}
+
+	tx, err := db.NewTransaction(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	// Expired keys are not live even before they are removed.
+	if s := db.Stats(); s.Keys != 1 {
+		t.Fatalf("want 1 key after expiry, got %d", s.Keys)
+	}
+
+	// Rollbacks of the expired transactions are not counted.
+	db.GC(ctx)
+	if err := tx.Rollback(ctx); !errors.Is(err, ErrExpired) {
+		t.Fatalf("want ErrExpired, got %v", err)
+	}
+	if s := db.Stats(); s.Keys != 1 || s.Rollbacks != 0 {
+		t.Fatalf("want 1 key and no rollbacks, got %+v", *s)
+	}
+
+	// Keys that are set again without a TTL are live.
+	set := func(ctx context.Context, rw kv.ReadWriter) error {
+		return rw.Set(ctx, "short", strings.NewReader("value"))
+	}
+	if err := kv.WithReadWriter(ctx, db, set); err != nil {
+		t.Fatal(err)
+	}
+	if s := db.Stats(); s.Keys != 2 {
+		t.Fatalf("want 2 keys, got %d", s.Keys)
+	}
+}
+
+func TestStatsOldestPin(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := New()
+	empty, err := db.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer empty.Discard(ctx)
+
+	for i := 0; i < 5; i++ {
+		set := func(ctx context.Context, rw kv.ReadWriter) error {
+			return rw.Set(ctx, "key", strings.NewReader("value"))
+		}
+		if err := kv.WithReadWriter(ctx, db, set); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snap, err := db.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Discard(ctx)
+
+	// Result must not depend on the map iteration order.
+	for i := 0; i < 100; i++ {
+		if s := db.Stats(); s.Pins != 2 || s.OldestPin != 0 {
+			t.Fatalf("want 2 pins with the oldest pin at version 0, got %+v", *s)
+		}
+	}
+}
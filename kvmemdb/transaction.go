@@ -9,7 +9,7 @@ import (
 	"io"
 	"os"
 	"slices"
-	"sort"
+	"time"
 
 	"github.com/bvkgo/kv"
 	"github.com/bvkgo/kv/internal/multival"
@@ -21,63 +21,227 @@ type Transaction struct {
 	version           int64
 	lastCommitVersion int64
 
+	// readTime holds the transaction creation time in unix nanoseconds. Values
+	// that expire before this time are not visible to the transaction.
+	readTime int64
+
+	// commitVersion holds the commit version assigned to the transaction after
+	// a successful commit.
+	commitVersion int64
+
+	isolation kv.IsolationLevel
+
+	// readOnly when true, transaction doesn't allow updates and doesn't track
+	// the accesses, so accesses map is nil.
+	readOnly bool
+
 	// accesses caches key-values that are read/written by this transaction.
 	accesses map[string]*multival.Value
+
+	// writes holds the sorted list of keys written by this transaction. Keys
+	// read by the transaction are already in the database, but written keys
+	// may not be, so they need to be merged into the range iterations.
+	writes []string
+
+	// merges holds the merge operands for the keys that are not written by
+	// this transaction. They are resolved at commit time.
+	merges map[string]*pendingMerge
+
+	// deletes holds the key ranges deleted by DeleteRange. Committed keys in
+	// these ranges are deleted at commit time, unless they are written again
+	// by this transaction.
+	deletes []keyRange
+
+	// ranges holds the key ranges observed by this transaction through the
+	// iterators. It is used only for serializable transactions.
+	ranges []keyRange
+
+	// handle is non-nil when the handle tracking is enabled.
+	handle *handle
+}
+
+// keyRange represents a key range with the same begin and end conventions as
+// the kv.Ranger interface.
+type keyRange struct {
+	begin, end string
+}
+
+func (r keyRange) contains(key string) bool {
+	return key >= r.begin && (r.end == "" || key < r.end)
+}
+
+// observeRange records a key range observed by an iterator so that any keys
+// created or deleted in the range by other transactions can be detected at
+// commit.
+//
+// Entire range is recorded even if the iterator is stopped early, which can
+// report a few false conflicts, but keeps the validation simple.
+func (t *Transaction) observeRange(begin, end string) {
+	if !t.readOnly && t.isolation == kv.Serializable {
+		t.ranges = append(t.ranges, keyRange{begin: begin, end: end})
+	}
 }
 
 func (t *Transaction) String() string {
 	return fmt.Sprintf("TX-%d (%d)", t.version, t.lastCommitVersion)
 }
 
+// ReadVersion returns the commit version of the database data visible to the
+// transaction.
+func (t *Transaction) ReadVersion() int64 {
+	return t.lastCommitVersion
+}
+
+// CommitVersion returns the commit version assigned to the transaction after a
+// successful commit. Read-only transactions are not assigned a commit
+// version.
+func (t *Transaction) CommitVersion() (int64, bool) {
+	return t.commitVersion, t.commitVersion != 0
+}
+
 func (t *Transaction) Get(ctx context.Context, key string) (io.Reader, error) {
 	if len(key) == 0 {
 		return nil, os.ErrInvalid
 	}
+	if err := t.handle.check(); err != nil {
+		return nil, err
+	}
 
-	if v, ok := t.accesses[key]; ok {
-		if v.Deleted {
-			return nil, os.ErrNotExist
+	data, exists := t.get(key)
+	// Older versions can be removed as soon as the handle expires, so the
+	// handle is checked again after the read.
+	if err := t.handle.check(); err != nil {
+		return nil, err
+	}
+	if pm, ok := t.merges[key]; ok {
+		merged, err := pm.resolve(key, data, exists)
+		if err != nil {
+			return nil, err
 		}
-		return bytes.NewReader(v.Data), nil
+		data, exists = merged, true
+	}
+	if !exists {
+		return nil, os.ErrNotExist
+	}
+	return bytes.NewReader(data), nil
+}
+
+// get returns the value of a key visible to the transaction, ignoring the
+// pending merges.
+func (t *Transaction) get(key string) ([]byte, bool) {
+	if v, ok := t.accesses[key]; ok && v.Version == t.version {
+		return v.Data, v.Visible(t.readTime)
+	}
+	if t.rangeDeleted(key) {
+		return nil, false
+	}
+	if v, ok := t.accesses[key]; ok {
+		return v.Data, v.Visible(t.readTime)
 	}
 
 	if mv, ok := t.db.store.Load(key); ok {
 		if v, ok := mv.Fetch(t.lastCommitVersion); ok {
-			// Make a local copy of the already-committed value.
-			t.accesses[key] = v
-			if !v.Deleted {
-				return bytes.NewReader(v.Data), nil
+			if !t.readOnly {
+				// Make a local copy of the already-committed value.
+				t.accesses[key] = v
 			}
-			return nil, os.ErrNotExist
+			return v.Data, v.Visible(t.readTime)
 		}
 	}
 
-	return nil, os.ErrNotExist
+	return nil, false
 }
 
 func (t *Transaction) Set(ctx context.Context, key string, value io.Reader) error {
+	return t.set(key, value, 0)
+}
+
+// MultiGet reads multiple keys in the transaction. Keys that are read are
+// added to the transaction as with Get.
+func (t *Transaction) MultiGet(ctx context.Context, keys []string) ([]io.Reader, []error, error) {
+	if err := t.handle.check(); err != nil {
+		return nil, nil, err
+	}
+	values := make([]io.Reader, len(keys))
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		values[i], errs[i] = t.Get(ctx, key)
+	}
+	return values, errs, nil
+}
+
+// MultiSet creates or updates multiple keys. All values are read before any
+// key is updated, so the transaction is left unchanged on errors.
+func (t *Transaction) MultiSet(ctx context.Context, kvs map[string]io.Reader) error {
+	if t.readOnly {
+		return fmt.Errorf("transaction is read-only: %w", os.ErrPermission)
+	}
+	if err := t.handle.check(); err != nil {
+		return err
+	}
+
+	values := make(map[string][]byte, len(kvs))
+	for key, value := range kvs {
+		if len(key) == 0 {
+			return os.ErrInvalid
+		}
+		data, err := io.ReadAll(value)
+		if err != nil {
+			return err
+		}
+		values[key] = data
+	}
+	for key, data := range values {
+		if err := t.set(key, bytes.NewReader(data), 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetWithTTL is same as Set, but the key expires after the given duration.
+// Expired keys are not visible to the snapshots and transactions created
+// after the expiry and are removed by the garbage collection.
+func (t *Transaction) SetWithTTL(ctx context.Context, key string, value io.Reader, ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("ttl must be positive: %w", os.ErrInvalid)
+	}
+	return t.set(key, value, time.Now().Add(ttl).UnixNano())
+}
+
+func (t *Transaction) set(key string, value io.Reader, expiresAt int64) error {
 	if len(key) == 0 {
 		return os.ErrInvalid
 	}
+	if t.readOnly {
+		return fmt.Errorf("transaction is read-only: %w", os.ErrPermission)
+	}
+	if err := t.handle.check(); err != nil {
+		return err
+	}
 
 	data, err := io.ReadAll(value)
 	if err != nil {
 		return err
 	}
 
+	delete(t.merges, key)
 	if v, ok := t.accesses[key]; ok {
 		// Do not modify the values that are not created by this transaction.
 		if v.Version == t.version {
 			v.Data = data
 			v.Deleted = false
+			v.ExpiresAt = expiresAt
 			return nil
 		}
 	}
 
 	t.accesses[key] = &multival.Value{
-		Version: t.version,
-		Data:    data,
+		Version:   t.version,
+		Data:      data,
+		ExpiresAt: expiresAt,
 	}
+	t.addWrite(key)
 	return nil
 }
 
@@ -85,12 +249,20 @@ func (t *Transaction) Delete(ctx context.Context, key string) error {
 	if len(key) == 0 {
 		return os.ErrInvalid
 	}
+	if t.readOnly {
+		return fmt.Errorf("transaction is read-only: %w", os.ErrPermission)
+	}
+	if err := t.handle.check(); err != nil {
+		return err
+	}
 
+	delete(t.merges, key)
 	if v, ok := t.accesses[key]; ok {
 		// Do not modify the values that are not created by this transaction.
 		if v.Version == t.version {
 			v.Data = nil
 			v.Deleted = true
+			v.ExpiresAt = 0
 			return nil
 		}
 	}
@@ -99,62 +271,172 @@ func (t *Transaction) Delete(ctx context.Context, key string) error {
 		Version: t.version,
 		Deleted: true,
 	}
+	t.addWrite(key)
 	return nil
 }
 
-func (t *Transaction) Ascend(ctx context.Context, begin, end string) (kv.Iterator, error) {
+// DeleteRange deletes all keys in the input range. Deleted range is recorded
+// as a tombstone, so keys in the range are not added to the transaction and
+// the committed keys in the range are deleted at commit time.
+func (t *Transaction) DeleteRange(ctx context.Context, begin, end string) error {
 	if end != "" && begin > end {
-		return nil, os.ErrInvalid
+		return os.ErrInvalid
+	}
+	if t.readOnly {
+		return fmt.Errorf("transaction is read-only: %w", os.ErrPermission)
+	}
+	if err := t.handle.check(); err != nil {
+		return err
+	}
+
+	for _, key := range t.localKeys(begin, end) {
+		if v, ok := t.accesses[key]; ok && v.Version == t.version {
+			v.Data = nil
+			v.Deleted = true
+			v.ExpiresAt = 0
+		}
+		delete(t.merges, key)
+	}
+	t.deletes = append(t.deletes, keyRange{begin: begin, end: end})
+	return nil
+}
+
+// rangeDeleted returns true if the key is in a range deleted by the
+// transaction.
+func (t *Transaction) rangeDeleted(key string) bool {
+	for _, r := range t.deletes {
+		if r.contains(key) {
+			return true
+		}
 	}
-	keys := t.db.keys(t.accesses)
-	for k := range t.accesses {
-		keys = append(keys, k)
+	return false
+}
+
+// addWrite adds a key to the sorted list of written keys.
+func (t *Transaction) addWrite(key string) {
+	if i, found := slices.BinarySearch(t.writes, key); !found {
+		t.writes = slices.Insert(t.writes, i, key)
 	}
-	sort.Strings(keys)
-	i, n := 0, len(keys)
+}
+
+// localKeys returns the sorted list of keys written by the transaction in the
+// input range.
+func (t *Transaction) localKeys(begin, end string) []string {
+	i, n := 0, len(t.writes)
 	if begin != "" {
-		i, _ = slices.BinarySearch(keys, begin)
+		i, _ = slices.BinarySearch(t.writes, begin)
 	}
 	if end != "" {
-		n, _ = slices.BinarySearch(keys, end)
+		n, _ = slices.BinarySearch(t.writes, end)
 	}
-	return newIterator(t.Get, keys[i:n], false /* descending */), nil
+	return slices.Clone(t.writes[i:n])
 }
 
-func (t *Transaction) Descend(ctx context.Context, begin, end string) (kv.Iterator, error) {
+func (t *Transaction) Ascend(ctx context.Context, begin, end string) (kv.Iterator, error) {
 	if end != "" && begin > end {
 		return nil, os.ErrInvalid
 	}
-	keys := t.db.keys(t.accesses)
-	for k := range t.accesses {
-		keys = append(keys, k)
+	if err := t.handle.check(); err != nil {
+		return nil, err
 	}
-	sort.Strings(keys)
-	i, n := 0, len(keys)
-	if begin != "" {
-		i, _ = slices.BinarySearch(keys, begin)
+	t.observeRange(begin, end)
+	keys := t.localKeys(begin, end)
+	return newIterator(t.Get, t.handle, t.db.store.Cursor(), begin, end, keys, false /* descending */), nil
+}
+
+func (t *Transaction) Descend(ctx context.Context, begin, end string) (kv.Iterator, error) {
+	if end != "" && begin > end {
+		return nil, os.ErrInvalid
 	}
-	if end != "" {
-		n, _ = slices.BinarySearch(keys, end)
+	if err := t.handle.check(); err != nil {
+		return nil, err
 	}
-	return newIterator(t.Get, keys[i:n], true /* descending */), nil
+	t.observeRange(begin, end)
+	keys := t.localKeys(begin, end)
+	return newIterator(t.Get, t.handle, t.db.store.Cursor(), begin, end, keys, true /* descending */), nil
 }
 
 func (t *Transaction) Scan(ctx context.Context) (kv.Iterator, error) {
-	keys := t.db.keys(t.accesses)
-	for k := range t.accesses {
-		keys = append(keys, k)
+	if err := t.handle.check(); err != nil {
+		return nil, err
 	}
-	return newIterator(t.Get, keys, false /* descending */), nil
+	t.observeRange("", "")
+	keys := t.localKeys("", "")
+	return newIterator(t.Get, t.handle, t.db.store.Cursor(), "", "", keys, false /* descending */), nil
+}
+
+// getKey is same as Get, but returns a nil value, so that the keys-only
+// iterators do not need to resolve the pending merges.
+func (t *Transaction) getKey(ctx context.Context, key string) (io.Reader, error) {
+	if err := t.handle.check(); err != nil {
+		return nil, err
+	}
+	_, exists := t.get(key)
+	if err := t.handle.check(); err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, nil
+	}
+	if _, ok := t.merges[key]; ok {
+		return nil, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+func (t *Transaction) AscendKeys(ctx context.Context, begin, end string) (kv.Iterator, error) {
+	if end != "" && begin > end {
+		return nil, os.ErrInvalid
+	}
+	if err := t.handle.check(); err != nil {
+		return nil, err
+	}
+	t.observeRange(begin, end)
+	keys := t.localKeys(begin, end)
+	return newIterator(t.getKey, t.handle, t.db.store.Cursor(), begin, end, keys, false /* descending */), nil
+}
+
+func (t *Transaction) DescendKeys(ctx context.Context, begin, end string) (kv.Iterator, error) {
+	if end != "" && begin > end {
+		return nil, os.ErrInvalid
+	}
+	if err := t.handle.check(); err != nil {
+		return nil, err
+	}
+	t.observeRange(begin, end)
+	keys := t.localKeys(begin, end)
+	return newIterator(t.getKey, t.handle, t.db.store.Cursor(), begin, end, keys, true /* descending */), nil
+}
+
+func (t *Transaction) ScanKeys(ctx context.Context) (kv.Iterator, error) {
+	if err := t.handle.check(); err != nil {
+		return nil, err
+	}
+	t.observeRange("", "")
+	keys := t.localKeys("", "")
+	return newIterator(t.getKey, t.handle, t.db.store.Cursor(), "", "", keys, false /* descending */), nil
+}
+
+// Count returns the number of keys in a range visible to the transaction,
+// including the keys written by the transaction.
+func (t *Transaction) Count(ctx context.Context, begin, end string) (int64, error) {
+	it, err := t.AscendKeys(ctx, begin, end)
+	if err != nil {
+		return 0, err
+	}
+	return countKeys(ctx, it)
 }
 
 func (t *Transaction) Rollback(ctx context.Context) error {
 	if t.db == nil {
 		return os.ErrClosed
 	}
-	t.db.releasePin(t.lastCommitVersion)
+	err := t.db.releasePin(t.lastCommitVersion, t.handle)
+	if err == nil {
+		t.db.numRollbacks.Add(1)
+	}
 	t.db = nil
-	return nil
+	return err
 }
 
 func (t *Transaction) Commit(ctx context.Context) error {
@@ -162,9 +444,19 @@ func (t *Transaction) Commit(ctx context.Context) error {
 		return os.ErrClosed
 	}
 	defer func() {
-		t.db.releasePin(t.lastCommitVersion)
+		t.db.releasePin(t.lastCommitVersion, t.handle)
 		t.db = nil
 	}()
 
-	return t.db.commit(t)
+	if err := t.handle.check(); err != nil {
+		return err
+	}
+
+	if !t.readOnly {
+		if err := t.db.commit(t); err != nil {
+			return err
+		}
+	}
+	t.db.numCommits.Add(1)
+	return nil
 }
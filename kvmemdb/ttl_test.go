@@ -0,0 +1,124 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bvkgo/kv"
+)
+
+func TestTTL(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	dir := t.TempDir()
+	db, err := Open(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { db.Close() }()
+
+	setTTL := func(key string, ttl time.Duration) {
+		set := func(ctx context.Context, rw kv.ReadWriter) error {
+			return rw.(kv.TTLSetter).SetWithTTL(ctx, key, strings.NewReader("value"), ttl)
+		}
+		if err := kv.WithReadWriter(ctx, db, set); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setTTL("short", 50*time.Millisecond)
+	setTTL("long", time.Hour)
+
+	tx, err := db.NewTransaction(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.(kv.TTLSetter).SetWithTTL(ctx, "key", strings.NewReader("value"), 0); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("want ErrInvalid for zero ttl, got %v", err)
+	}
+	tx.Rollback(ctx)
+
+	count := func(snap kv.Snapshot) int {
+		it, err := snap.Scan(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for _, _, err := it.Fetch(ctx, false); err == nil; _, _, err = it.Fetch(ctx, true) {
+			n++
+		}
+		return n
+	}
+
+	old, err := db.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := count(old); n != 2 {
+		t.Fatalf("want 2 keys before the expiry, got %d", n)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	snap, err := db.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := snap.Get(ctx, "short"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("want ErrNotExist for the expired key, got %v", err)
+	}
+	if n := count(snap); n != 1 {
+		t.Fatalf("want 1 key after the expiry, got %d", n)
+	}
+	snap.Discard(ctx)
+
+	// Snapshots created before the expiry still see the key and keep it from
+	// being collected.
+	if _, err := old.Get(ctx, "short"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GC(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s := db.Stats(); s.Keys != 1 || s.Versions != 2 {
+		t.Fatalf("want 1 live key and 2 versions with an older snapshot, got %+v", *s)
+	}
+	old.Discard(ctx)
+
+	// Expiry times are preserved by the recovery.
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if db, err = Open(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	snap, err = db.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := snap.Get(ctx, "short"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("want ErrNotExist after the recovery, got %v", err)
+	}
+	if r, err := snap.Get(ctx, "long"); err != nil {
+		t.Fatal(err)
+	} else if data, _ := io.ReadAll(r); string(data) != "value" {
+		t.Fatalf("want value, got %q", data)
+	}
+	snap.Discard(ctx)
+
+	// One more commit moves the GC horizon past the expired key.
+	setTTL("long", time.Hour)
+	if _, err := db.GC(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s := db.Stats(); s.Keys != 1 || s.Versions != 1 {
+		t.Fatalf("want expired key to be collected, got %+v", *s)
+	}
+}
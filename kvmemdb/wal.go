@@ -0,0 +1,274 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kvmemdb
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bvkgo/kv/internal/multival"
+)
+
+// SyncPolicy determines when the write-ahead log is synced to the disk.
+type SyncPolicy int
+
+const (
+	// SyncAlways syncs the write-ahead log before every commit is completed.
+	SyncAlways SyncPolicy = iota
+
+	// SyncInterval syncs the write-ahead log periodically. Commits in the last
+	// interval may be lost if the machine crashes.
+	SyncInterval
+
+	// SyncNever leaves syncing the write-ahead log to the operating system.
+	SyncNever
+)
+
+const (
+	walPrefix = "wal-"
+	walSuffix = ".log"
+
+	checkpointFile = "checkpoint.db"
+)
+
+// write represents an update to a key by a commit.
+type write struct {
+	key   string
+	value *multival.Value
+}
+
+// wal represents a write-ahead log, which is a sequence of segment files in
+// a directory. Each segment holds one record per commit and is named after
+// the first commit version that it can hold. New segments are started when
+// the database is checkpointed, so that older segments can be removed after
+// the checkpoint is complete.
+type wal struct {
+	mu sync.Mutex
+
+	dir  string
+	sync SyncPolicy
+
+	fp    *os.File
+	bufw  *bufio.Writer
+	size  int64
+	dirty bool
+
+	// buf is reused for encoding the records.
+	buf []byte
+}
+
+func segmentName(version int64) string {
+	return fmt.Sprintf("%s%020d%s", walPrefix, version, walSuffix)
+}
+
+// listSegments returns the start versions of all wal segments in the
+// directory in increasing order.
+func listSegments(dir string) ([]int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var versions []int64
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, walPrefix) || !strings.HasSuffix(name, walSuffix) {
+			continue
+		}
+		v, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, walPrefix), walSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	slices.Sort(versions)
+	return versions, nil
+}
+
+// openWAL opens the wal segment for appending new records.
+func openWAL(dir string, start int64, sync SyncPolicy) (*wal, error) {
+	w := &wal{dir: dir, sync: sync}
+	if err := w.openSegment(start); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *wal) openSegment(start int64) error {
+	fp, err := os.OpenFile(filepath.Join(w.dir, segmentName(start)), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	stat, err := fp.Stat()
+	if err != nil {
+		fp.Close()
+		return err
+	}
+	if err := syncDir(w.dir); err != nil {
+		fp.Close()
+		return err
+	}
+	w.fp = fp
+	w.bufw = bufio.NewWriter(fp)
+	w.size = stat.Size()
+	return nil
+}
+
+// append adds a commit record to the log. Record is synced to the disk
+// before returning when sync policy is SyncAlways.
+func (w *wal) append(version int64, writes []write) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.fp == nil {
+		return os.ErrClosed
+	}
+
+	buf := appendCommit(w.buf[:0], version, writes)
+	w.buf = buf
+
+	n, err := writeRecord(w.bufw, buf)
+	if err == nil {
+		err = w.bufw.Flush()
+	}
+	if err != nil {
+		// Remove the partial record, so that log remains usable.
+		w.bufw.Reset(w.fp)
+		if terr := w.fp.Truncate(w.size); terr != nil {
+			return errors.Join(err, terr)
+		}
+		return err
+	}
+	w.size += int64(n)
+	w.dirty = true
+
+	if w.sync == SyncAlways {
+		return w.syncLocked()
+	}
+	return nil
+}
+
+func (w *wal) syncLocked() error {
+	if w.fp == nil || !w.dirty {
+		return nil
+	}
+	if err := w.fp.Sync(); err != nil {
+		return err
+	}
+	w.dirty = false
+	return nil
+}
+
+func (w *wal) syncFile() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.syncLocked()
+}
+
+// rotate closes the current segment and starts a new segment for the commits
+// from the input version.
+func (w *wal) rotate(start int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.fp == nil {
+		return os.ErrClosed
+	}
+	if err := w.syncLocked(); err != nil {
+		return err
+	}
+	if err := w.fp.Close(); err != nil {
+		return err
+	}
+	w.fp = nil
+	return w.openSegment(start)
+}
+
+func (w *wal) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.fp == nil {
+		return os.ErrClosed
+	}
+	err := w.syncLocked()
+	if cerr := w.fp.Close(); err == nil {
+		err = cerr
+	}
+	w.fp = nil
+	return err
+}
+
+// replaySegment reads all commit records from a wal segment in order and
+// passes them to the apply function.
+//
+// When truncate is true, an incomplete or corrupted record at the end is
+// treated as a partial write from a crash and is removed from the file along
+// with all data after it. Otherwise, such records are reported as errors.
+func replaySegment(file string, truncate bool, apply func(version int64, writes []write) error) error {
+	flags := os.O_RDONLY
+	if truncate {
+		flags = os.O_RDWR
+	}
+	fp, err := os.OpenFile(file, flags, 0)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	r := &countingReader{r: bufio.NewReader(fp)}
+	for {
+		offset := r.n
+		payload, err := readRecord(r)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err == nil {
+			var version int64
+			var writes []write
+			if version, writes, err = decodeCommit(payload); err == nil {
+				if err := apply(version, writes); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !truncate || !(errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, errChecksum)) {
+			return fmt.Errorf("could not read wal record at offset %d in %q: %w", offset, file, err)
+		}
+		if err := fp.Truncate(offset); err != nil {
+			return err
+		}
+		return fp.Sync()
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// syncDir syncs the directory entries to the disk, which is necessary for
+// the newly created or renamed files to survive a crash.
+func syncDir(dir string) error {
+	fp, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	return fp.Sync()
+}
@@ -0,0 +1,224 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bvkgo/kv"
+)
+
+func dumpDB(ctx context.Context, db *DB) (map[string]string, error) {
+	state := make(map[string]string)
+	dump := func(ctx context.Context, r kv.Reader) error {
+		it, err := r.Scan(ctx)
+		if err != nil {
+			return err
+		}
+		defer kv.Close(it)
+
+		for k, v, err := it.Fetch(ctx, false); err == nil; k, v, err = it.Fetch(ctx, true) {
+			data, err := io.ReadAll(v)
+			if err != nil {
+				return err
+			}
+			state[k] = string(data)
+		}
+		if _, _, err := it.Fetch(ctx, false); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		return nil
+	}
+	if err := kv.WithReader(ctx, db, dump); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func TestWALRecovery(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	dir := t.TempDir()
+	opts := &Options{CheckpointInterval: -1}
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sizes[i] holds the wal size after i commits and states[i] holds the
+	// expected database content at that point.
+	segment := filepath.Join(dir, segmentName(1))
+	sizes := []int64{0}
+	states := []map[string]string{{}}
+
+	state := make(map[string]string)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%02d", rand.Intn(20))
+		value := strings.Repeat(fmt.Sprint(i), 1+rand.Intn(10))
+		del := rand.Intn(4) == 0
+		update := func(ctx context.Context, rw kv.ReadWriter) error {
+			if del {
+				if err := rw.Delete(ctx, key); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return err
+				}
+				return nil
+			}
+			return rw.Set(ctx, key, strings.NewReader(value))
+		}
+		if err := kv.WithReadWriter(ctx, db, update); err != nil {
+			t.Fatal(err)
+		}
+		if del {
+			delete(state, key)
+		} else {
+			state[key] = value
+		}
+
+		stat, err := os.Stat(segment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, stat.Size())
+		states = append(states, maps.Clone(state))
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := os.ReadFile(segment)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Truncate the log at random offsets and at the record boundaries.
+	offsets := append([]int64{}, sizes...)
+	for i := 0; i < 100; i++ {
+		offsets = append(offsets, rand.Int63n(int64(len(log))+1))
+	}
+
+	for _, offset := range offsets {
+		ndir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(ndir, segmentName(1)), log[:offset], 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		// Find the last commit that is completely written before the offset.
+		ncommits := 0
+		for i, size := range sizes {
+			if size <= offset {
+				ncommits = i
+			}
+		}
+
+		ndb, err := Open(ndir, opts)
+		if err != nil {
+			t.Fatalf("offset %d: %v", offset, err)
+		}
+		got, err := dumpDB(ctx, ndb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := states[ncommits]; !maps.Equal(got, want) {
+			t.Fatalf("offset %d: want %v, got %v", offset, want, got)
+		}
+
+		// Database must be usable after the recovery.
+		set := func(ctx context.Context, rw kv.ReadWriter) error {
+			return rw.Set(ctx, "recovered", strings.NewReader("yes"))
+		}
+		if err := kv.WithReadWriter(ctx, ndb, set); err != nil {
+			t.Fatalf("offset %d: %v", offset, err)
+		}
+		if err := ndb.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		ndb, err = Open(ndir, opts)
+		if err != nil {
+			t.Fatalf("offset %d: %v", offset, err)
+		}
+		got, err = dumpDB(ctx, ndb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := maps.Clone(states[ncommits])
+		want["recovered"] = "yes"
+		if !maps.Equal(got, want) {
+			t.Fatalf("offset %d: after reopen: want %v, got %v", offset, want, got)
+		}
+		if err := ndb.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	dir := t.TempDir()
+	opts := &Options{CheckpointInterval: -1}
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 30; i++ {
+		set := func(ctx context.Context, rw kv.ReadWriter) error {
+			return rw.Set(ctx, fmt.Sprintf("key%d", i%10), strings.NewReader(fmt.Sprint(i)))
+		}
+		if err := kv.WithReadWriter(ctx, db, set); err != nil {
+			t.Fatal(err)
+		}
+		if i%10 == 9 {
+			if err := db.Checkpoint(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	want, err := dumpDB(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Old segments must be removed after the checkpoints.
+	segments, err := listSegments(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 1 || segments[0] != 31 {
+		t.Fatalf("want one segment from version 31, got %v", segments)
+	}
+
+	db, err = Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	got, err := dumpDB(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(got, want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	if db.maxCommitVersion != 30 {
+		t.Fatalf("want max commit version 30, got %d", db.maxCommitVersion)
+	}
+}
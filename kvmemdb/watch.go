@@ -0,0 +1,217 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kvmemdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/bvkgo/kv"
+)
+
+// watchBatchSize is the maximum number of commits copied by a watcher with
+// the database lock held.
+const watchBatchSize = 64
+
+// defaultWatchHistory is the default number of commits retained for the
+// watchers. It is also the limit for the open watchers when the retention is
+// disabled.
+const defaultWatchHistory = 1000
+
+// commitChanges holds the values written by a commit in the increasing order
+// of keys.
+type commitChanges struct {
+	version int64
+	writes  []write
+}
+
+// recordChangesLocked adds the writes from a commit to the change history and
+// wakes up the watchers. When the retention is disabled, nothing is recorded
+// without any watchers.
+//
+// Caller must hold the db.mu lock.
+func (db *DB) recordChangesLocked(version int64, writes []write) {
+	limit := db.opts.WatchHistory
+	if limit < 0 {
+		if db.numWatchers == 0 {
+			db.changesFrom = version + 1
+			return
+		}
+		limit = defaultWatchHistory
+	}
+
+	if len(writes) > 0 {
+		sorted := make([]write, len(writes))
+		copy(sorted, writes)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].key < sorted[j].key })
+		db.changes = append(db.changes, commitChanges{version: version, writes: sorted})
+	}
+
+	if n := len(db.changes) - limit; n > 0 {
+		db.changesFrom = db.changes[n-1].version + 1
+		clear(db.changes[:n])
+		db.changes = db.changes[n:]
+	}
+
+	if db.changed != nil {
+		close(db.changed)
+		db.changed = nil
+	}
+}
+
+// Watch returns an iterator over the changes committed to the keys in the
+// given range. Recent commits are retained as per the WatchHistory option, so
+// watchers can resume from an older commit version.
+func (db *DB) Watch(ctx context.Context, begin, end string, opts *kv.WatchOptions) (kv.ChangeIterator, error) {
+	if end != "" && begin > end {
+		return nil, os.ErrInvalid
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return nil, os.ErrClosed
+	}
+
+	after := db.maxCommitVersion
+	if opts != nil && opts.After > 0 {
+		if opts.After > db.maxCommitVersion {
+			return nil, fmt.Errorf("version %d is not committed yet: %w", opts.After, os.ErrInvalid)
+		}
+		if opts.After+1 < db.changesFrom {
+			return nil, fmt.Errorf("changes after version %d are not retained: %w", opts.After, os.ErrNotExist)
+		}
+		after = opts.After
+	}
+
+	w := &watcher{
+		db:    db,
+		r:     keyRange{begin: begin, end: end},
+		after: after,
+		done:  make(chan struct{}),
+	}
+	db.numWatchers++
+	return w, nil
+}
+
+// watcher implements the kv.ChangeIterator interface.
+type watcher struct {
+	db *DB
+
+	r keyRange
+
+	// after holds the version of the last commit scanned by the watcher.
+	after int64
+
+	// pending holds the changes scanned, but not yet returned.
+	pending []*kv.Change
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func (w *watcher) Next(ctx context.Context) (*kv.Change, error) {
+	for len(w.pending) == 0 {
+		select {
+		case <-w.done:
+			return nil, os.ErrClosed
+		default:
+		}
+
+		changed, err := w.scan()
+		if err != nil {
+			return nil, err
+		}
+		if len(w.pending) > 0 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		case <-w.done:
+			return nil, os.ErrClosed
+		case <-w.db.done:
+			return nil, os.ErrClosed
+		case <-changed:
+		}
+	}
+
+	c := w.pending[0]
+	w.pending[0] = nil
+	w.pending = w.pending[1:]
+	return c, nil
+}
+
+// scan copies the changes after the last scanned version into the pending
+// list. Returns a channel that is closed on the next commit.
+func (w *watcher) scan() (<-chan struct{}, error) {
+	db := w.db
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if w.after+1 < db.changesFrom {
+		return nil, fmt.Errorf("changes after version %d are not retained: %w", w.after, kv.ErrLagged)
+	}
+
+	i := sort.Search(len(db.changes), func(i int) bool {
+		return db.changes[i].version > w.after
+	})
+	for n := 0; i < len(db.changes) && n < watchBatchSize; i, n = i+1, n+1 {
+		cc := &db.changes[i]
+		for _, wr := range cc.writes {
+			if !w.r.contains(wr.key) {
+				continue
+			}
+			c := &kv.Change{
+				Key:     wr.key,
+				Deleted: wr.value.Deleted,
+				Version: cc.version,
+			}
+			if !c.Deleted {
+				c.Value = bytes.Clone(wr.value.Data)
+				if c.Value == nil {
+					c.Value = []byte{}
+				}
+			}
+			w.pending = append(w.pending, c)
+		}
+		w.after = cc.version
+	}
+	if i == len(db.changes) {
+		w.after = db.maxCommitVersion
+	}
+
+	if db.changed == nil {
+		db.changed = make(chan struct{})
+	}
+	return db.changed, nil
+}
+
+func (w *watcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+		w.db.removeWatcher()
+	})
+	return nil
+}
+
+// removeWatcher drops the change history when the last watcher is closed and
+// the retention is disabled.
+func (db *DB) removeWatcher() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.numWatchers--
+	if db.numWatchers == 0 && db.opts.WatchHistory < 0 {
+		db.changesFrom = db.maxCommitVersion + 1
+		clear(db.changes)
+		db.changes = nil
+	}
+}
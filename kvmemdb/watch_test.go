@@ -0,0 +1,217 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bvkgo/kv"
+)
+
+func TestWatch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := New()
+	update := func(kvs ...string) {
+		f := func(ctx context.Context, rw kv.ReadWriter) error {
+			for i := 0; i < len(kvs); i += 2 {
+				if kvs[i+1] == "" {
+					if err := rw.Delete(ctx, kvs[i]); err != nil {
+						return err
+					}
+					continue
+				}
+				if err := rw.Set(ctx, kvs[i], strings.NewReader(kvs[i+1])); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if err := kv.WithReadWriter(ctx, db, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w, err := db.Watch(ctx, "b", "d", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// Watcher waiting for a change must be woken up by the commit.
+	type result struct {
+		c   *kv.Change
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		c, err := w.Next(ctx)
+		ch <- result{c, err}
+	}()
+	time.Sleep(time.Millisecond)
+
+	update("a", "1")                   // version 1
+	update("b", "2")                   // version 2
+	update("c", "3", "b", "", "a", "") // version 3
+	update("d", "4")                   // version 4
+
+	want := []kv.Change{
+		{Key: "b", Value: []byte("2"), Version: 2},
+		{Key: "b", Deleted: true, Version: 3},
+		{Key: "c", Value: []byte("3"), Version: 3},
+	}
+	check := func(w kv.ChangeIterator, want []kv.Change, first *kv.Change) {
+		for i, wc := range want {
+			c := first
+			if i > 0 || c == nil {
+				if c, err = w.Next(ctx); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if c.Key != wc.Key || string(c.Value) != string(wc.Value) || c.Deleted != wc.Deleted || c.Version != wc.Version {
+				t.Fatalf("change %d: want %+v, got %+v", i, wc, *c)
+			}
+		}
+		tctx, tcancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer tcancel()
+		if c, err := w.Next(tctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("want no more changes, got %v, %v", c, err)
+		}
+	}
+
+	r := <-ch
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	check(w, want, r.c)
+
+	// Watchers can resume from an older version.
+	w2, err := db.Watch(ctx, "b", "d", &kv.WatchOptions{After: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(w2, want[1:], nil)
+	if err := w2.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w2.Next(ctx); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("want ErrClosed, got %v", err)
+	}
+
+	if _, err := db.Watch(ctx, "", "", &kv.WatchOptions{After: 10}); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("want ErrInvalid, got %v", err)
+	}
+}
+
+func TestWatchLagged(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := NewWithOptions(&Options{WatchHistory: 5})
+	w, err := db.Watch(ctx, "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	for i := 0; i < 10; i++ {
+		set := func(ctx context.Context, rw kv.ReadWriter) error {
+			return rw.Set(ctx, fmt.Sprintf("key%d", i), strings.NewReader("value"))
+		}
+		if err := kv.WithReadWriter(ctx, db, set); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Commits must not be blocked by the slow watcher.
+	if _, err := w.Next(ctx); !errors.Is(err, kv.ErrLagged) {
+		t.Fatalf("want ErrLagged, got %v", err)
+	}
+	if _, err := db.Watch(ctx, "", "", &kv.WatchOptions{After: 2}); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("want ErrNotExist, got %v", err)
+	}
+
+	// Watcher can resume from the oldest retained changes.
+	w2, err := db.Watch(ctx, "", "", &kv.WatchOptions{After: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w2.Close()
+	for i := 5; i < 10; i++ {
+		c, err := w2.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("key%d", i); c.Key != want {
+			t.Fatalf("want %s, got %s", want, c.Key)
+		}
+	}
+
+	// Watchers are stopped when the database is closed.
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w2.Next(ctx); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("want ErrClosed, got %v", err)
+	}
+}
+
+func TestWatchHistory(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, history := range []int{0, -1} {
+		db := NewWithOptions(&Options{WatchHistory: history})
+		set := func(key string) {
+			f := func(ctx context.Context, rw kv.ReadWriter) error {
+				return rw.Set(ctx, key, strings.NewReader("value"))
+			}
+			if err := kv.WithReadWriter(ctx, db, f); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		w, err := db.Watch(ctx, "", "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		set("a") // version 1
+		c, err := w.Next(ctx)
+		if err != nil || c.Key != "a" {
+			t.Fatalf("history %d: want change for a, got %v, %v", history, c, err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Only watcher is closed and more commits are made before it resumes.
+		set("b")
+		set("c")
+		w, err = db.Watch(ctx, "", "", &kv.WatchOptions{After: c.Version})
+		if history < 0 {
+			if !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("want ErrNotExist with the retention disabled, got %v", err)
+			}
+			if n := len(db.changes); n != 0 {
+				t.Fatalf("want no changes without watchers, got %d", n)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{"b", "c"} {
+			if c, err := w.Next(ctx); err != nil || c.Key != want {
+				t.Fatalf("want change for %s, got %v, %v", want, c, err)
+			}
+		}
+		w.Close()
+	}
+}
@@ -299,6 +299,11 @@ func (s *TemplateStep) checkStatus(r *TemplateStepResult) error {
 			return nil
 		}
 	}
+	if errors.Is(r.Status, kv.ErrConflict) {
+		if slices.Contains(errs, "ErrConflict") {
+			return nil
+		}
+	}
 
 	if slices.Contains(errs, "non-nil") {
 		return nil
@@ -191,6 +191,6 @@ var TxOpsTemplateMap = map[string]string{
   tx:tx2  get key:account1              => value:100
   tx:tx2  get key:account2              => value:100
   tx:tx2  set key:account2 value:-100
-  tx:tx2  commit                        => error:non-nil
+  tx:tx2  commit                        => error:ErrConflict
 `,
 }
@@ -0,0 +1,102 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kv
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// RetryOptions controls the retry loop in WithRetry and WithReadWriterRetry.
+// Zero values for the fields are replaced with their defaults.
+type RetryOptions struct {
+	// MaxAttempts is the maximum number of times the input function is
+	// run. Negative value means no limit. Default is 10.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry. Delay doubles for
+	// every retry after that up to the MaxBackoff. Default is 1ms.
+	InitialBackoff time.Duration
+
+	// MaxBackoff is the upper limit for delay between retries. Default is 1s.
+	MaxBackoff time.Duration
+
+	// Retryable reports if an error returned by the input function should be
+	// retried. Default is to retry only on ErrConflict errors.
+	Retryable func(error) bool
+}
+
+func (opts *RetryOptions) setDefaults() {
+	if opts.MaxAttempts == 0 {
+		opts.MaxAttempts = 10
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = time.Millisecond
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = time.Second
+	}
+	if opts.MaxBackoff < opts.InitialBackoff {
+		opts.MaxBackoff = opts.InitialBackoff
+	}
+	if opts.Retryable == nil {
+		opts.Retryable = func(err error) bool {
+			return errors.Is(err, ErrConflict)
+		}
+	}
+}
+
+// WithRetry runs the input function repeatedly till it succeeds or returns a
+// non-retryable error or the number of attempts are exhausted. Nil options
+// use the default values.
+//
+// Retries are delayed with an exponential backoff and a random jitter. Retries
+// stop when the input context is canceled, in which case the last error from
+// the input function is returned.
+func WithRetry(ctx context.Context, opts *RetryOptions, f func(context.Context) error) error {
+	var o RetryOptions
+	if opts != nil {
+		o = *opts
+	}
+	o.setDefaults()
+
+	backoff := o.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := f(ctx)
+		if err == nil {
+			return nil
+		}
+		if !o.Retryable(err) {
+			return err
+		}
+		if o.MaxAttempts > 0 && attempt >= o.MaxAttempts {
+			return err
+		}
+
+		// Sleep for a random duration in [backoff/2, backoff) range.
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		if backoff *= 2; backoff > o.MaxBackoff {
+			backoff = o.MaxBackoff
+		}
+	}
+}
+
+// WithReadWriterRetry is same as WithReadWriter, but runs the input function
+// under a new transaction again when the transaction fails with a retryable
+// error (ErrConflict by default). Input function can be run multiple times, so
+// it must not have side-effects outside of the transaction.
+func WithReadWriterRetry(ctx context.Context, db Database, opts *RetryOptions, f func(context.Context, ReadWriter) error) error {
+	return WithRetry(ctx, opts, func(ctx context.Context) error {
+		return WithReadWriter(ctx, db, f)
+	})
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kv
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+)
+
+// All returns a range-over-func sequence over the key-value pairs from an
+// iterator and a function that returns the iteration error, if any, after
+// the loop. Reaching the end of the iterator or breaking out of the loop is
+// not an error.
+//
+//	seq, errf := kv.All(ctx, it)
+//	for k, v := range seq {
+//	  ...
+//	}
+//	if err := errf(); err != nil {
+//	  return err
+//	}
+//
+// Sequence resumes from the current iterator position, so it is typically
+// used only once.
+func All(ctx context.Context, it Iterator) (iter.Seq2[string, io.Reader], func() error) {
+	var err error
+	seq := func(yield func(string, io.Reader) bool) {
+		var k string
+		var v io.Reader
+		for k, v, err = it.Fetch(ctx, false); err == nil; k, v, err = it.Fetch(ctx, true) {
+			if !yield(k, v) {
+				return
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	}
+	return seq, func() error { return err }
+}
+
+// Range is same as All, but iterates over a range of keys in ascending order.
+// Iterator is created when the loop starts and is closed when the loop ends.
+// Errors from creating the iterator are also reported by the error function.
+func Range(ctx context.Context, r Ranger, begin, end string) (iter.Seq2[string, io.Reader], func() error) {
+	var err error
+	seq := func(yield func(string, io.Reader) bool) {
+		it, e := r.Ascend(ctx, begin, end)
+		if e != nil {
+			err = e
+			return
+		}
+		defer Close(it)
+
+		s, errf := All(ctx, it)
+		s(yield)
+		err = errf()
+	}
+	return seq, func() error { return err }
+}
@@ -4,6 +4,7 @@ package kv
 
 import (
 	"context"
+	"errors"
 	"io"
 )
 
@@ -25,6 +26,52 @@ func Close(v Iterator) error {
 	return nil
 }
 
+// NewTransaction creates a new transaction with the input options. Databases
+// that do not implement TransactionOpener interface can only create
+// transactions with the default options, so errors.ErrUnsupported is returned
+// for them if any option is set.
+func NewTransaction(ctx context.Context, db Database, opts *TransactionOptions) (Transaction, error) {
+	if v, ok := db.(TransactionOpener); ok {
+		return v.NewTransactionWithOptions(ctx, opts)
+	}
+	if opts != nil && *opts != (TransactionOptions{}) {
+		return nil, errors.ErrUnsupported
+	}
+	return db.NewTransaction(ctx)
+}
+
+// DeleteRange removes all key-value pairs in a range. It uses the
+// RangeDeleter interface when the transaction implements it and deletes the
+// keys one by one otherwise.
+func DeleteRange(ctx context.Context, rw ReadWriter, begin, end string) error {
+	if v, ok := rw.(RangeDeleter); ok {
+		return v.DeleteRange(ctx, begin, end)
+	}
+
+	it, err := AscendKeys(ctx, rw, begin, end)
+	if err != nil {
+		return err
+	}
+	defer Close(it)
+
+	// Keys are collected first cause some backends may not allow updates
+	// while iterating.
+	var keys []string
+	for k, _, err := it.Fetch(ctx, false); err == nil; k, _, err = it.Fetch(ctx, true) {
+		keys = append(keys, k)
+	}
+	if _, _, err := it.Fetch(ctx, false); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	for _, k := range keys {
+		if err := rw.Delete(ctx, k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // WithReader runs the input function under a temporary snapshot.
 func WithReader(ctx context.Context, db Database, f func(context.Context, Reader) error) error {
 	snap, err := db.NewSnapshot(ctx)
@@ -0,0 +1,102 @@
+// Copyright (c) 2023 BVK Chaitanya
+
+package kv
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrLagged is returned (possibly wrapped) by ChangeIterator.Next when the
+// watcher has fallen so far behind that the changes it hasn't received yet
+// are not retained by the database anymore. Watchers can recover by reading
+// a new snapshot and watching again from the snapshot version.
+var ErrLagged = errors.New("watcher has fallen behind the retained changes")
+
+// Change represents a committed update to a key.
+type Change struct {
+	Key string
+
+	// Value holds the new value for the key. It is nil when the key is
+	// deleted.
+	Value []byte
+
+	// Deleted is true if the key is deleted by the commit.
+	Deleted bool
+
+	// Version is the commit version of the update.
+	Version int64
+}
+
+// ChangeIterator delivers the committed changes to a watcher or the versions
+// of a key from the Historian interface.
+type ChangeIterator interface {
+	// Next returns the next change, waiting for a new commit if necessary.
+	// Changes are returned in the order of their commit versions and changes
+	// from the same commit are returned in the increasing order of keys.
+	//
+	// Next returns an error wrapping ErrLagged if the changes are not
+	// retained anymore, the context error if the context is canceled while
+	// waiting and os.ErrClosed after the iterator is closed. Iterators over a
+	// fixed set of changes, like the key histories, return io.EOF at the end
+	// instead of waiting.
+	Next(ctx context.Context) (*Change, error)
+
+	// Close releases the iterator.
+	Close() error
+}
+
+// WatchOptions holds optional parameters for watching the changes.
+type WatchOptions struct {
+	// After when positive resumes the watch from the changes committed after
+	// the given version, which is typically the version of the last change
+	// received by a previous watcher or the version of a snapshot. Default is
+	// to watch for the changes committed after the Watch call.
+	After int64
+}
+
+// Watcher is an optional interface implemented by databases that can
+// stream the committed changes.
+//
+// Databases retain a limited history of the changes. Commits are never
+// blocked by the watchers, so watchers that cannot keep up with the commits
+// receive an ErrLagged error instead.
+type Watcher interface {
+	// Watch returns an iterator over the changes committed to the keys in the
+	// given range. Begin and end follow the same conventions as the Ranger
+	// interface. Nil options are same as the default options.
+	//
+	// Returns os.ErrNotExist if changes after the requested version are not
+	// retained anymore and os.ErrInvalid if the version is not committed yet.
+	Watch(ctx context.Context, begin, end string, opts *WatchOptions) (ChangeIterator, error)
+}
+
+// NewChangeList returns a ChangeIterator over a fixed list of changes, which
+// returns io.EOF after the last change.
+func NewChangeList(changes []*Change) ChangeIterator {
+	return &changeList{changes: changes}
+}
+
+type changeList struct {
+	changes []*Change
+	closed  bool
+}
+
+func (l *changeList) Next(ctx context.Context) (*Change, error) {
+	if l.closed {
+		return nil, os.ErrClosed
+	}
+	if len(l.changes) == 0 {
+		return nil, io.EOF
+	}
+	c := l.changes[0]
+	l.changes = l.changes[1:]
+	return c, nil
+}
+
+func (l *changeList) Close() error {
+	l.closed = true
+	return nil
+}